@@ -0,0 +1,153 @@
+package analysis
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestVet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; puts(x)", []string{}},
+		{"puts(y)", []string{"1:6: undefined identifier y (undefined)"}},
+		{"y = 1", []string{"1:1: undefined identifier y (undefined)"}},
+		{"let x = x + 1", []string{"1:9: undefined identifier x (undefined)"}},
+		{"let f = fn() { g() }; let g = fn() { f() }; f()", []string{}},
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }", []string{}},
+		{"later(); fn later() { 1 }", []string{}},
+		{"if (true) { let a = 1 }; a", []string{}},
+		{"match (1) { x if x > 0 => x, _ => x }", []string{"1:35: undefined identifier x (undefined)"}},
+		{"try { throw 1 } catch (e) { e }; e", []string{"1:34: undefined identifier e (undefined)"}},
+		{"struct P { x  fn get() { self.x } } let p = P { x: 1 }; p.get()", []string{}},
+		{"let [a, ...b] = [1]; let {\"k\": c = a} = {}; puts(a, b, c)", []string{}},
+		{"self", []string{"1:1: undefined identifier self (undefined)"}},
+
+		{"let f = fn() { let x = 1; 2 }; f()", []string{"1:20: x declared but not used (unused)"}},
+		{"let f = fn() { let _x = 1; let [_, y] = [1, 2]; 2 }; f()", []string{"1:36: y declared but not used (unused)"}},
+		{"let f = fn() { let x = 1; fn() { x } }; f()", []string{}},
+		{"let x = 1", []string{}},
+		{"let f = fn(unusedParam) { 1 }; f(1)", []string{}},
+
+		{"let len = 1; len", []string{"1:5: len shadows a builtin function (shadow-builtin)"}},
+		{"let f = fn(puts) { puts }; f(1)", []string{"1:12: puts shadows a builtin function (shadow-builtin)"}},
+		{"match (1) { first => first }", []string{"1:13: first shadows a builtin function (shadow-builtin)"}},
+
+		{"let f = fn() { return 1; puts(2) }; f()", []string{"1:26: unreachable code (unreachable)"}},
+		{"let f = fn() { throw 1; 2; 3 }; f()", []string{"1:25: unreachable code (unreachable)"}},
+		{"let f = fn() { return 1; return 2 }; f()", []string{"1:26: unreachable code (unreachable)"}},
+		{"let f = fn() { return g(); fn g() { 1 } }; f()", []string{}},
+		{"let f = fn(x) { if (x) { return 1 } 2 }; f(1)", []string{}},
+
+		{"let add = fn(a, b) { a + b }; add(1)", []string{"1:31: wrong number of arguments to add. got=1, want=2 (arity)"}},
+		{"fn add(a, b = 1) { a + b }; add(1); add(1, 2); add(1, 2, 3)",
+			[]string{"1:48: wrong number of arguments to add. got=3, want=1 or 2 (arity)"}},
+		{"let add = fn(a) { a }; add = fn(a, b) { a + b }; add(1, 2)", []string{}},
+		{"let f = fn(add) { add(1, 2, 3) }; f(fn(x) { x })", []string{}},
+		{"[1] |> fn(x) { x }()", []string{}},
+		{"let f = fn(x) { x }; 1 |> f()", []string{}},
+		{"len(1, 2)", []string{}},
+	}
+
+	for _, tt := range tests {
+		diagnostics := Vet(parse(t, tt.input), nil)
+
+		got := []string{}
+		for _, d := range diagnostics {
+			got = append(got, d.String())
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: wrong diagnostics.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%q: wrong diagnostic %d.\nexpected=%q\ngot=%q", tt.input, i, tt.expected[i], got[i])
+			}
+		}
+	}
+}
+
+func TestVetDisabled(t *testing.T) {
+	program := parse(t, "let len = fn() { let x = 1; return 1; y }; len(1)")
+
+	all := Vet(program, nil)
+	if len(all) != 5 {
+		t.Fatalf("expected 5 diagnostics, got %v", all)
+	}
+
+	for _, rule := range Rules {
+		for _, d := range Vet(program, map[string]bool{rule: true}) {
+			if d.Rule == rule {
+				t.Errorf("disabled rule %s reported: %s", rule, d)
+			}
+		}
+	}
+
+	if got := Vet(program, map[string]bool{UNDEFINED: true, UNUSED: true,
+		SHADOWBUILTIN: true, UNREACHABLE: true, ARITY: true}); len(got) != 0 {
+		t.Errorf("expected no diagnostics with every rule disabled, got %v", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	program := parse(t, "let x = 1; let f = fn(y) { x + y }; f(x)")
+	info := Resolve(program)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	binding := info.Defs[let.Name]
+	if binding == nil || binding.Kind != LET {
+		t.Fatalf("x not defined by its let statement. got=%+v", binding)
+	}
+	if len(binding.Uses) != 2 {
+		t.Fatalf("wrong number of uses of x. expected=2, got=%d", len(binding.Uses))
+	}
+	for _, use := range binding.Uses {
+		if info.Uses[use] != binding {
+			t.Errorf("use of x at %d:%d not resolved to its binding", use.Token.Line, use.Token.Column)
+		}
+	}
+
+	f := info.Defs[program.Statements[1].(*ast.LetStatement).Name]
+	if f.Function == nil || len(f.Function.Parameters) != 1 {
+		t.Errorf("f not bound to its function literal. got=%+v", f.Function)
+	}
+
+	call := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if info.Uses[call.Function.(*ast.Identifier)] != f {
+		t.Errorf("callee not resolved to f")
+	}
+	if info.Uses[call.Function.(*ast.Identifier)].Function.Parameters[0].(*ast.Identifier).Value != "y" {
+		t.Errorf("wrong parameter for f")
+	}
+}
+
+func TestReferences(t *testing.T) {
+	program := parse(t, "let n = 1;\nn = n + 1;\nlet f = fn(n) { n };\nf(n)")
+	info := Resolve(program)
+
+	binding := info.Defs[program.Statements[0].(*ast.LetStatement).Name]
+	expected := []string{"1:5", "2:1", "2:5", "4:3"}
+
+	got := []string{}
+	for _, ref := range info.References(binding) {
+		got = append(got, fmt.Sprintf("%d:%d", ref.Token.Line, ref.Token.Column))
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong references.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
@@ -0,0 +1,360 @@
+// Package analysis finds likely mistakes in Monkey programs without
+// running them. Resolve works out what every identifier refers to, and Vet
+// checks the program against a set of rules.
+package analysis
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"sort"
+)
+
+// Kinds of Binding
+const (
+	BUILTIN   = "builtin"
+	LET       = "let"
+	CONST     = "const"
+	FUNCTION  = "fn"
+	PARAMETER = "parameter"
+	STRUCT    = "struct"
+	PATTERN   = "pattern" // bound by a match arm
+	CATCH     = "catch"
+	SELF      = "self"
+)
+
+// Binding is a name introduced by a declaration
+type Binding struct {
+	Name string
+	Kind string
+	Def  *ast.Identifier // where the name is bound, nil for builtins and self
+
+	// Decl is the node declaring the name: the let, fn or struct statement,
+	// the function literal of a parameter, the match arm of a pattern or
+	// the try expression of a catch parameter
+	Decl ast.Node
+
+	// Function is the function bound by a declaration, set for let and
+	// const bindings of function literals and for fn statements
+	Function *ast.FunctionLiteral
+
+	Uses     []*ast.Identifier
+	Assigned bool // the name is the target of an assignment
+
+	local bool // bound inside a function, where an unused let is reported
+}
+
+// Info is the result of resolving a program
+type Info struct {
+	Bindings   []*Binding // in the order they were declared
+	Defs       map[*ast.Identifier]*Binding
+	Uses       map[*ast.Identifier]*Binding
+	Unresolved []*ast.Identifier // identifiers bound nowhere
+
+	calls       []call
+	unreachable []ast.Statement
+}
+
+// References returns the identifiers bound to binding, the declaration
+// first when there is one, then the uses and assignments in source order
+func (info *Info) References(binding *Binding) []*ast.Identifier {
+	refs := []*ast.Identifier{}
+	for ident, b := range info.Uses {
+		if b == binding {
+			refs = append(refs, ident)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Token.Line != refs[j].Token.Line {
+			return refs[i].Token.Line < refs[j].Token.Line
+		}
+		return refs[i].Token.Column < refs[j].Token.Column
+	})
+
+	if binding.Def != nil {
+		refs = append([]*ast.Identifier{binding.Def}, refs...)
+	}
+	return refs
+}
+
+// call is a call of a function through an identifier, kept for checking
+// its arguments once every binding is known
+type call struct {
+	expression *ast.CallExpression
+	callee     *ast.Identifier
+}
+
+type scope struct {
+	outer *scope
+	names map[string]*Binding
+	local bool
+}
+
+func newScope(outer *scope) *scope {
+	s := &scope{outer: outer, names: make(map[string]*Binding)}
+	if outer != nil {
+		s.local = outer.local
+	}
+	return s
+}
+
+func (s *scope) lookup(name string) *Binding {
+	for ; s != nil; s = s.outer {
+		if binding, ok := s.names[name]; ok {
+			return binding
+		}
+	}
+	return nil
+}
+
+// universe holds the builtins every program can use
+func universe() *scope {
+	s := newScope(nil)
+	for _, name := range evaluator.BuiltinNames() {
+		s.names[name] = &Binding{Name: name, Kind: BUILTIN}
+	}
+	return s
+}
+
+// resolver walks a program in the order it runs. Function bodies are
+// resolved after the scopes around them are complete, since they run later
+// and may refer to names declared after them
+type resolver struct {
+	info     *Info
+	deferred []func()
+}
+
+// Resolve binds the identifiers of program to their declarations. Blocks
+// share the scope of the function around them, while function calls, match
+// arms and catch blocks have scopes of their own, as in the evaluator
+func Resolve(program *ast.Program) *Info {
+	r := &resolver{info: &Info{
+		Defs: make(map[*ast.Identifier]*Binding),
+		Uses: make(map[*ast.Identifier]*Binding),
+	}}
+
+	r.statements(program.Statements, newScope(universe()))
+	for len(r.deferred) > 0 {
+		next := r.deferred[0]
+		r.deferred = r.deferred[1:]
+		next()
+	}
+
+	return r.info
+}
+
+func (r *resolver) declare(s *scope, ident *ast.Identifier, kind string, decl ast.Node) *Binding {
+	binding := &Binding{Name: ident.Value, Kind: kind, Def: ident, Decl: decl, local: s.local}
+	if ident.Value != "_" {
+		s.names[ident.Value] = binding
+	}
+
+	r.info.Bindings = append(r.info.Bindings, binding)
+	r.info.Defs[ident] = binding
+	return binding
+}
+
+func (r *resolver) use(s *scope, ident *ast.Identifier) *Binding {
+	binding := s.lookup(ident.Value)
+	if binding == nil {
+		r.info.Unresolved = append(r.info.Unresolved, ident)
+		return nil
+	}
+
+	binding.Uses = append(binding.Uses, ident)
+	r.info.Uses[ident] = binding
+	return binding
+}
+
+func (r *resolver) statements(stmts []ast.Statement, s *scope) {
+	for _, stmt := range stmts {
+		if fn, ok := stmt.(*ast.FunctionStatement); ok {
+			binding := r.declare(s, fn.Name, FUNCTION, fn)
+			binding.Function = fn.Function
+		}
+	}
+
+	// only the first unreachable statement is reported. Function
+	// declarations are hoisted, so they are not code that never runs
+	exited, reported := false, false
+	for _, stmt := range stmts {
+		_, declaration := stmt.(*ast.FunctionStatement)
+		if exited && !declaration && !reported {
+			r.info.unreachable = append(r.info.unreachable, stmt)
+			reported = true
+		}
+
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			exited = true
+		}
+		r.statement(stmt, s)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement, s *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.expression(stmt.Value, s)
+
+		kind := LET
+		if stmt.Token.Literal == "const" {
+			kind = CONST
+		}
+
+		if stmt.Pattern != nil {
+			r.pattern(stmt.Pattern, s, s, kind, stmt)
+			return
+		}
+		binding := r.declare(s, stmt.Name, kind, stmt)
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			binding.Function = fn
+		}
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue, s)
+	case *ast.ThrowStatement:
+		r.expression(stmt.Value, s)
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression, s)
+	case *ast.FunctionStatement:
+		r.function(stmt.Function, s, false)
+	case *ast.StructStatement:
+		r.declare(s, stmt.Name, STRUCT, stmt)
+		for _, method := range stmt.Methods {
+			r.function(method.Function, s, true)
+		}
+	case *ast.BlockStatement:
+		r.statements(stmt.Statements, s)
+	}
+}
+
+// function resolves the parameters and body of fn once the scope it closes
+// over is complete
+func (r *resolver) function(fn *ast.FunctionLiteral, outer *scope, method bool) {
+	r.deferred = append(r.deferred, func() {
+		s := newScope(outer)
+		s.local = true
+		if method {
+			s.names["self"] = &Binding{Name: "self", Kind: SELF}
+		}
+
+		for _, param := range fn.Parameters {
+			r.pattern(param, s, s, PARAMETER, fn)
+		}
+		r.statements(fn.Body.Statements, s)
+	})
+}
+
+// pattern declares the names pattern binds in s, resolving the expressions
+// it holds, such as default values, in from
+func (r *resolver) pattern(pattern ast.Expression, s, from *scope, kind string, decl ast.Node) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		r.declare(s, pattern, kind, decl)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			r.pattern(element, s, from, kind, decl)
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			r.declare(s, pattern.Rest, kind, decl)
+		}
+	case *ast.HashPattern:
+		for i, key := range pattern.Keys {
+			r.expression(key, from)
+			r.pattern(pattern.Values[i], s, from, kind, decl)
+		}
+	case *ast.DefaultPattern:
+		r.expression(pattern.Default, from)
+		r.pattern(pattern.Pattern, s, from, kind, decl)
+	}
+}
+
+func (r *resolver) expression(e ast.Expression, s *scope) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		r.use(s, e)
+	case *ast.PrefixExpression:
+		r.expression(e.Right, s)
+	case *ast.InfixExpression:
+		r.expression(e.Left, s)
+		r.expression(e.Right, s)
+	case *ast.AssignExpression:
+		r.expression(e.Value, s)
+		if ident, ok := e.Target.(*ast.Identifier); ok {
+			if binding := s.lookup(ident.Value); binding != nil {
+				binding.Assigned = true
+				r.info.Uses[ident] = binding
+			} else {
+				r.info.Unresolved = append(r.info.Unresolved, ident)
+			}
+			return
+		}
+		r.expression(e.Target, s)
+	case *ast.InterpolatedString:
+		for _, part := range e.Parts {
+			r.expression(part, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range e.Elements {
+			r.expression(element, s)
+		}
+	case *ast.HashLiteral:
+		for _, key := range e.Keys {
+			r.expression(key, s)
+			r.expression(e.Pairs[key], s)
+		}
+	case *ast.StructLiteral:
+		r.expression(e.Type, s)
+		for _, value := range e.Values {
+			r.expression(value, s)
+		}
+	case *ast.IndexExpression:
+		r.expression(e.Left, s)
+		r.expression(e.Index, s)
+	case *ast.SliceExpression:
+		r.expression(e.Left, s)
+		r.expression(e.Start, s)
+		r.expression(e.End, s)
+	case *ast.MemberExpression:
+		r.expression(e.Object, s)
+	case *ast.CallExpression:
+		r.expression(e.Function, s)
+		for _, arg := range e.Arguments {
+			r.expression(arg, s)
+		}
+		if ident, ok := e.Function.(*ast.Identifier); ok {
+			r.info.calls = append(r.info.calls, call{expression: e, callee: ident})
+		}
+	case *ast.FunctionLiteral:
+		r.function(e, s, false)
+	case *ast.IfExpression:
+		r.expression(e.Condition, s)
+		r.statement(e.Consequence, s)
+		if e.Alternative != nil {
+			r.statement(e.Alternative, s)
+		}
+	case *ast.TryExpression:
+		r.statement(e.Block, s)
+		if e.Catch != nil {
+			catch := newScope(s)
+			if e.Param != nil {
+				r.declare(catch, e.Param, CATCH, e)
+			}
+			r.statement(e.Catch, catch)
+		}
+		if e.Finally != nil {
+			r.statement(e.Finally, s)
+		}
+	case *ast.MatchExpression:
+		r.expression(e.Subject, s)
+		for _, arm := range e.Arms {
+			armScope := newScope(s)
+			r.pattern(arm.Pattern, armScope, s, PATTERN, arm)
+			r.expression(arm.Guard, armScope)
+			if block, ok := arm.Body.(*ast.BlockStatement); ok {
+				r.statement(block, armScope)
+			} else {
+				r.expression(arm.Body.(ast.Expression), armScope)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"fmt"
+	"monkey/ast"
+	"sort"
+	"strings"
+)
+
+// Rule IDs, used to name diagnostics and to disable them
+const (
+	UNDEFINED     = "undefined"      // an identifier that is bound nowhere
+	UNUSED        = "unused"         // a local let binding that is never used
+	SHADOWBUILTIN = "shadow-builtin" // a binding hiding a builtin function
+	UNREACHABLE   = "unreachable"    // code after a return or throw
+	ARITY         = "arity"          // a call with the wrong number of arguments
+)
+
+// Rules lists every rule Vet checks
+var Rules = []string{UNDEFINED, UNUSED, SHADOWBUILTIN, UNREACHABLE, ARITY}
+
+// Diagnostic is a problem found in a program
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Rule    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Rule)
+}
+
+// Vet checks program against every rule not in disabled, returning the
+// diagnostics in source order
+func Vet(program *ast.Program, disabled map[string]bool) []Diagnostic {
+	info := Resolve(program)
+	builtins := universe().names
+	diagnostics := []Diagnostic{}
+
+	report := func(rule string, line, column int, format string, a ...interface{}) {
+		if !disabled[rule] {
+			diagnostics = append(diagnostics, Diagnostic{
+				Line:    line,
+				Column:  column,
+				Rule:    rule,
+				Message: fmt.Sprintf(format, a...),
+			})
+		}
+	}
+
+	for _, ident := range info.Unresolved {
+		report(UNDEFINED, ident.Token.Line, ident.Token.Column,
+			"undefined identifier %s", ident.Value)
+	}
+
+	for _, binding := range info.Bindings {
+		def := binding.Def.Token
+		if binding.local && (binding.Kind == LET || binding.Kind == CONST) &&
+			len(binding.Uses) == 0 && !strings.HasPrefix(binding.Name, "_") {
+			report(UNUSED, def.Line, def.Column, "%s declared but not used", binding.Name)
+		}
+		if builtins[binding.Name] != nil {
+			report(SHADOWBUILTIN, def.Line, def.Column, "%s shadows a builtin function", binding.Name)
+		}
+	}
+
+	for _, stmt := range info.unreachable {
+		tok := ast.StatementToken(stmt)
+		report(UNREACHABLE, tok.Line, tok.Column, "unreachable code")
+	}
+
+	for _, c := range info.calls {
+		binding := info.Uses[c.callee]
+		if binding == nil || binding.Function == nil || binding.Assigned {
+			continue
+		}
+
+		required, total := arity(binding.Function)
+		got := len(c.expression.Arguments)
+		if got >= required && got <= total {
+			continue
+		}
+
+		want := []string{}
+		for n := required; n <= total; n++ {
+			want = append(want, fmt.Sprint(n))
+		}
+		tok := c.callee.Token
+		report(ARITY, tok.Line, tok.Column, "wrong number of arguments to %s. got=%d, want=%s",
+			binding.Name, got, strings.Join(want, " or "))
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics
+}
+
+// arity returns the least and most arguments fn accepts. Parameters with
+// defaults may be left out
+func arity(fn *ast.FunctionLiteral) (required, total int) {
+	for _, param := range fn.Parameters {
+		if _, ok := param.(*ast.DefaultPattern); !ok {
+			required++
+		}
+	}
+	return required, len(fn.Parameters)
+}
@@ -82,11 +82,12 @@ func (b *Boolean) String() string {
 	return b.Token.Literal
 }
 
-// LetStatement is the node for let statement
+// LetStatement is the node for let and const statements
 type LetStatement struct {
-	Token token.Token // the token.Let Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // the token.LET or token.CONST Token
+	Name    *Identifier
+	Pattern Expression // set instead of Name when destructuring
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode() {}
@@ -99,7 +100,11 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -137,6 +142,32 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// ThrowStatement is the node for throw statement
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
+// TokenLiteral for ThrowStatement
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+
+// String returns the Throw Node
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
 // ExpressionStatement is the node for expressions
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
@@ -156,10 +187,32 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// StatementToken returns the token stmt starts with
+func StatementToken(stmt Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		return stmt.Token
+	case *ReturnStatement:
+		return stmt.Token
+	case *ThrowStatement:
+		return stmt.Token
+	case *ExpressionStatement:
+		return stmt.Token
+	case *FunctionStatement:
+		return stmt.Token
+	case *StructStatement:
+		return stmt.Token
+	case *BlockStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
 // BlockStatement for blocks
 type BlockStatement struct {
 	Token      token.Token // the  { token
 	Statements []Statement
+	Rbrace     token.Token // the } token, unset for blocks the parser makes up
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -231,8 +284,9 @@ func (ie *IfExpression) String() string {
 
 // FunctionLiteral defines function
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
+	Token      token.Token  // The 'fn' token, or '|' for lambdas
+	Name       string       // set by declarations and let bindings
+	Parameters []Expression // identifiers or destructuring patterns
 	Body       *BlockStatement
 }
 
@@ -252,6 +306,12 @@ func (fl *FunctionLiteral) String() string {
 		params = append(params, p.String())
 	}
 
+	if fl.Token.Type == token.BAR {
+		out.WriteString("|" + strings.Join(params, ", ") + "| ")
+		out.WriteString(fl.Body.String())
+		return out.String()
+	}
+
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
@@ -266,6 +326,7 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Pipe      bool // written as Arguments[0] |> Function(Arguments[1:]...)
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -284,11 +345,20 @@ func (ce *CallExpression) String() string {
 		args = append(args, a.String())
 	}
 
+	if ce.Pipe {
+		out.WriteString("(" + args[0] + " |> ")
+		args = args[1:]
+	}
+
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 
+	if ce.Pipe {
+		out.WriteString(")")
+	}
+
 	return out.String()
 }
 
@@ -362,6 +432,38 @@ func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// InterpolatedString is a string literal with embedded expressions. Its
+// parts alternate between string literals and the embedded expressions
+type InterpolatedString struct {
+	Token token.Token // the token.TEMPLATE_HEAD token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+
+// TokenLiteral returns the interpolated string token literal
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+// String returns the interpolated string repr
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if str, ok := part.(*StringLiteral); ok {
+			out.WriteString(str.String())
+			continue
+		}
+
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
 // ArrayLiteral provides structure for Array
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
@@ -419,10 +521,46 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression provides structure for slicing arrays and strings
+type SliceExpression struct {
+	Token token.Token // The [ token
+	Left  Expression
+	Start Expression // nil when omitted
+	End   Expression // nil when omitted
+}
+
+func (se *SliceExpression) expressionNode() {}
+
+// TokenLiteral represents the Token literal
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+// String represents the slice repr
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("]")
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // HashLiteral provides structure for hash
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -437,8 +575,188 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// ImportExpression loads another source file as a module
+type ImportExpression struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+}
+
+func (ie *ImportExpression) expressionNode() {}
+
+// TokenLiteral returns the import token literal
+func (ie *ImportExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+// String returns the import repr
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " \"" + ie.Path.String() + "\""
+}
+
+// TryExpression handles errors raised while evaluating Block
+type TryExpression struct {
+	Token   token.Token // the 'try' token
+	Block   *BlockStatement
+	Param   *Identifier // nil when catch takes no parameter
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+
+// TokenLiteral returns the try token literal
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+
+// String returns the try repr
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Param != nil {
+			out.WriteString("(" + te.Param.String() + ") ")
+		}
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// MatchExpression dispatches on the shape of Subject
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+// TokenLiteral returns the match token literal
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+// String returns the match repr
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") {")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// MatchArm is a single `pattern if guard => body` case of a match
+type MatchArm struct {
+	Token   token.Token // the first token of the pattern
+	Pattern Expression
+	Guard   Expression // nil without an if guard
+	Body    Node       // an Expression or a *BlockStatement
+}
+
+// TokenLiteral returns the arm token literal
+func (ma *MatchArm) TokenLiteral() string {
+	return ma.Token.Literal
+}
+
+// String returns the arm repr
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+}
+
+// ArrayPattern matches arrays element by element
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []Expression
+	Rest     *Identifier // binds the remaining elements, nil without `...`
+}
+
+func (ap *ArrayPattern) expressionNode() {}
+
+// TokenLiteral returns the array pattern token literal
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+
+// String returns the array pattern repr
+func (ap *ArrayPattern) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashPattern matches hashes that contain every key in Keys
+type HashPattern struct {
+	Token  token.Token // the '{' token
+	Keys   []Expression
+	Values []Expression // the pattern for the value under Keys[i]
+}
+
+func (hp *HashPattern) expressionNode() {}
+
+// TokenLiteral returns the hash pattern token literal
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+
+// String returns the hash pattern repr
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		pairs = append(pairs, key.String()+":"+hp.Values[i].String())
 	}
 
 	out.WriteString("{")
@@ -447,3 +765,188 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// DefaultPattern supplies a value for a pattern when nothing is there to
+// destructure
+type DefaultPattern struct {
+	Token   token.Token // the '=' token
+	Pattern Expression
+	Default Expression
+}
+
+func (dp *DefaultPattern) expressionNode() {}
+
+// TokenLiteral returns the default pattern token literal
+func (dp *DefaultPattern) TokenLiteral() string {
+	return dp.Token.Literal
+}
+
+// String returns the default pattern repr
+func (dp *DefaultPattern) String() string {
+	return dp.Pattern.String() + " = " + dp.Default.String()
+}
+
+// FunctionStatement declares a named function, hoisted to the top of the
+// enclosing program or block
+type FunctionStatement struct {
+	Token    token.Token // the token.FUNCTION token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode() {}
+
+// TokenLiteral returns the function statement token literal
+func (fs *FunctionStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+// String returns the function statement repr
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range fs.Function.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(fs.TokenLiteral() + " ")
+	out.WriteString(fs.Name.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(fs.Function.Body.String())
+
+	return out.String()
+}
+
+// StructStatement declares a struct type with named fields and methods
+type StructStatement struct {
+	Token   token.Token // the token.STRUCT token
+	Name    *Identifier
+	Fields  []*Identifier
+	Methods []*StructMethod
+}
+
+func (ss *StructStatement) statementNode() {}
+
+// TokenLiteral returns the struct statement token literal
+func (ss *StructStatement) TokenLiteral() string {
+	return ss.Token.Literal
+}
+
+// String returns the struct statement repr
+func (ss *StructStatement) String() string {
+	var out bytes.Buffer
+
+	members := []string{}
+	for _, field := range ss.Fields {
+		members = append(members, field.String())
+	}
+	for _, method := range ss.Methods {
+		members = append(members, method.String())
+	}
+
+	out.WriteString(ss.TokenLiteral() + " ")
+	out.WriteString(ss.Name.String())
+	out.WriteString(" {")
+	out.WriteString(strings.Join(members, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// StructMethod is a method defined inside a struct declaration
+type StructMethod struct {
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+// String returns the struct method repr
+func (sm *StructMethod) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range sm.Function.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(sm.Function.TokenLiteral() + " ")
+	out.WriteString(sm.Name.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(sm.Function.Body.String())
+
+	return out.String()
+}
+
+// StructLiteral constructs an instance of a struct type
+type StructLiteral struct {
+	Token  token.Token // the '{' token
+	Type   Expression
+	Fields []*Identifier
+	Values []Expression
+}
+
+func (sl *StructLiteral) expressionNode() {}
+
+// TokenLiteral returns the struct literal token literal
+func (sl *StructLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+
+// String returns the struct literal repr
+func (sl *StructLiteral) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for i, field := range sl.Fields {
+		fields = append(fields, field.String()+": "+sl.Values[i].String())
+	}
+
+	out.WriteString(sl.Type.String())
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// MemberExpression accesses a field or method with '.'
+type MemberExpression struct {
+	Token  token.Token // the '.' token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+
+// TokenLiteral returns the member expression token literal
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+// String returns the member expression repr
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Member.String() + ")"
+}
+
+// AssignExpression replaces the value of a variable, index or field
+type AssignExpression struct {
+	Token  token.Token // the '=' token
+	Target Expression  // Identifier, IndexExpression or MemberExpression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+// TokenLiteral returns the assign expression token literal
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+// String returns the assign expression repr
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " = " + ae.Value.String() + ")"
+}
@@ -0,0 +1,391 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"monkey/wire"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSource = `let total = 10;
+fn add(a, b) {
+    let sum = a + b;
+    return sum;
+}
+let pair = [add(1, 2), {"k": total}];
+puts(pair[0]);
+let y = add(pair[0], total);
+puts(y);
+`
+
+// testClient drives a server running in-process over a pair of pipes. A
+// goroutine reads everything the server writes, so the program may send
+// events at any time
+type testClient struct {
+	t        *testing.T
+	w        io.WriteCloser
+	seq      int
+	messages chan testMessage
+	events   []testMessage // read while waiting for something else
+	done     chan error
+}
+
+type testMessage struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func startTest(t *testing.T) *testClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	c := &testClient{t: t, w: inW, messages: make(chan testMessage, 100), done: make(chan error, 1)}
+	go func() {
+		err := Serve(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			content, err := wire.Read(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg testMessage
+			json.Unmarshal(content, &msg)
+			c.messages <- msg
+		}
+	}()
+
+	c.request("initialize", map[string]interface{}{"adapterID": "monkey"}, nil)
+	return c
+}
+
+// writeProgram writes source to a temporary file, returning its path
+func writeProgram(t *testing.T, source string) string {
+	f, err := ioutil.TempFile("", "dap*.monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if _, err := f.WriteString(source); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func (c *testClient) next() testMessage {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return testMessage{}
+}
+
+// call sends a request and returns its response, decoding the body into
+// body when it succeeded
+func (c *testClient) call(command string, args interface{}, body interface{}) testMessage {
+	c.seq++
+	msg := map[string]interface{}{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		msg["arguments"] = args
+	}
+	if err := wire.Write(c.w, msg); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.RequestSeq != c.seq {
+			c.t.Fatalf("response to request %d, want %d", msg.RequestSeq, c.seq)
+		}
+		if msg.Success && body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return msg
+	}
+}
+
+// request is call for requests that must succeed
+func (c *testClient) request(command string, args interface{}, body interface{}) {
+	if msg := c.call(command, args, body); !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+}
+
+// event waits for the next event named name, decoding its body into body,
+// and returns the output sent before it
+func (c *testClient) event(name string, body interface{}) string {
+	var output strings.Builder
+	for {
+		var msg testMessage
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg.Type != "event" {
+			c.t.Fatalf("unexpected response to request %d waiting for %s", msg.RequestSeq, name)
+		}
+
+		if msg.Event == "output" {
+			var o OutputEvent
+			json.Unmarshal(msg.Body, &o)
+			output.WriteString(o.Output)
+		}
+		if msg.Event == name {
+			if body != nil {
+				json.Unmarshal(msg.Body, body)
+			}
+			return output.String()
+		}
+	}
+}
+
+// stopped waits for the program to pause, checks why and where, and
+// returns the output before it paused
+func (c *testClient) stopped(reason string, line int, name string) string {
+	var stopped StoppedEvent
+	output := c.event("stopped", &stopped)
+	if stopped.Reason != reason || stopped.ThreadID != THREADID {
+		c.t.Fatalf("wrong stopped event. want=%s, got=%+v", reason, stopped)
+	}
+
+	var trace struct{ StackFrames []StackFrame }
+	c.request("stackTrace", StackTraceArguments{ThreadID: THREADID}, &trace)
+	top := trace.StackFrames[0]
+	if top.Line != line || top.Name != name {
+		c.t.Fatalf("stopped in the wrong place. want=%s:%d, got=%s:%d", name, line, top.Name, top.Line)
+	}
+	return output
+}
+
+// variables returns the variables listed by reference as "name=value"
+// strings, and their own references by name
+func (c *testClient) variables(reference int) ([]string, map[string]int) {
+	var body struct{ Variables []Variable }
+	c.request("variables", VariablesArguments{VariablesReference: reference}, &body)
+
+	got := []string{}
+	references := map[string]int{}
+	for _, v := range body.Variables {
+		got = append(got, v.Name+"="+v.Value)
+		references[v.Name] = v.VariablesReference
+	}
+	return got, references
+}
+
+func (c *testClient) disconnect() {
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("Serve returned %v", err)
+	}
+}
+
+func TestSession(t *testing.T) {
+	path := writeProgram(t, testSource)
+	c := startTest(t)
+
+	c.request("launch", LaunchArguments{Program: path}, nil)
+	c.event("initialized", nil)
+
+	var breakpoints struct{ Breakpoints []Breakpoint }
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: path},
+		Breakpoints: []SourceBreakpoint{{Line: 4}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 1 || !breakpoints.Breakpoints[0].Verified {
+		t.Fatalf("breakpoint not verified. got=%+v", breakpoints.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+	c.stopped("breakpoint", 4, "add")
+
+	var threads struct{ Threads []Thread }
+	c.request("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != THREADID {
+		t.Errorf("wrong threads. got=%+v", threads.Threads)
+	}
+
+	var trace struct {
+		StackFrames []StackFrame
+		TotalFrames int
+	}
+	c.request("stackTrace", StackTraceArguments{ThreadID: THREADID}, &trace)
+	if trace.TotalFrames != 2 || len(trace.StackFrames) != 2 {
+		t.Fatalf("wrong number of frames. got=%+v", trace)
+	}
+	caller := trace.StackFrames[1]
+	if caller.ID != 2 || caller.Name != "<program>" || caller.Line != 6 || caller.Source.Path != path {
+		t.Errorf("wrong caller frame. got=%+v", caller)
+	}
+
+	var scopes struct{ Scopes []Scope }
+	c.request("scopes", ScopesArguments{FrameID: 1}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes. got=%+v", scopes.Scopes)
+	}
+	locals, _ := c.variables(scopes.Scopes[0].VariablesReference)
+	if strings.Join(locals, " ") != "a=1 b=2 sum=3" {
+		t.Errorf("wrong locals. got=%q", locals)
+	}
+
+	var result EvaluateResponse
+	c.request("evaluate", EvaluateArguments{Expression: "sum * total", FrameID: 1}, &result)
+	if result.Result != "30" || result.Type != "INTEGER" {
+		t.Errorf("wrong evaluation. got=%+v", result)
+	}
+	if msg := c.call("evaluate", EvaluateArguments{Expression: "sum", FrameID: 2}, nil); msg.Success ||
+		msg.Message != "identifier not found: sum" {
+		t.Errorf("evaluating in the caller frame did not fail. got=%+v", msg)
+	}
+
+	c.request("next", nil, nil)
+	c.stopped("step", 7, "<program>")
+
+	c.request("scopes", ScopesArguments{FrameID: 1}, &scopes)
+	if len(scopes.Scopes) != 1 {
+		t.Fatalf("the program frame has locals. got=%+v", scopes.Scopes)
+	}
+	globals, references := c.variables(scopes.Scopes[0].VariablesReference)
+	if strings.Join(globals, " ") != `add=fn add(a, b) pair=[3, {"k": 10}] total=10` {
+		t.Errorf("wrong globals. got=%q", globals)
+	}
+	elements, references := c.variables(references["pair"])
+	if strings.Join(elements, " ") != `0=3 1={"k": 10}` || references["0"] != 0 {
+		t.Errorf("wrong array elements. got=%q", elements)
+	}
+	pairs, _ := c.variables(references["1"])
+	if strings.Join(pairs, " ") != `"k"=10` {
+		t.Errorf("wrong hash pairs. got=%q", pairs)
+	}
+
+	c.request("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}}, nil)
+	c.request("stepIn", nil, nil)
+	if output := c.stopped("step", 8, "<program>"); output != "3\n" {
+		t.Errorf("wrong output. got=%q", output)
+	}
+	c.request("stepIn", nil, nil)
+	c.stopped("step", 3, "add")
+	c.request("stepOut", nil, nil)
+	c.stopped("step", 9, "<program>")
+
+	if msg := c.call("variables", VariablesArguments{VariablesReference: references["1"]}, nil); msg.Success {
+		t.Errorf("variables reference kept after resuming")
+	}
+
+	c.request("continue", nil, nil)
+	var exited ExitedEvent
+	if output := c.event("exited", &exited); output != "13\n" || exited.ExitCode != 0 {
+		t.Errorf("wrong end of program. output=%q, exit code=%d", output, exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	if msg := c.call("continue", nil, nil); msg.Success || msg.Message != "the program is not paused" {
+		t.Errorf("continue after the end did not fail. got=%+v", msg)
+	}
+	c.disconnect()
+}
+
+func TestStopOnEntry(t *testing.T) {
+	path := writeProgram(t, testSource)
+	c := startTest(t)
+
+	c.request("launch", LaunchArguments{Program: path, StopOnEntry: true}, nil)
+	c.event("initialized", nil)
+
+	var breakpoints struct{ Breakpoints []Breakpoint }
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: path + ".other"},
+		Breakpoints: []SourceBreakpoint{{Line: 4}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 1 || !breakpoints.Breakpoints[0].Verified {
+		t.Errorf("breakpoint in a module not verified. got=%+v", breakpoints.Breakpoints)
+	}
+
+	c.request("configurationDone", nil, nil)
+	c.stopped("entry", 1, "<program>")
+
+	var result EvaluateResponse
+	c.request("evaluate", EvaluateArguments{Expression: "[1, add]"}, &result)
+	if result.Result != "[1, fn add(a, b) { let sum = (a + b);return sum; }]" || result.VariablesReference == 0 {
+		t.Errorf("wrong evaluation. got=%+v", result)
+	}
+
+	// disconnecting aborts the paused program
+	c.disconnect()
+	names := []string{}
+	for _, msg := range c.events {
+		names = append(names, msg.Event)
+	}
+	if strings.Join(names, " ") != "exited terminated" {
+		t.Errorf("wrong events on disconnect. got=%q", names)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := startTest(t)
+
+	tests := []struct {
+		command  string
+		args     interface{}
+		expected string
+	}{
+		{"launch", LaunchArguments{}, "no program to launch"},
+		{"launch", LaunchArguments{Program: writeProgram(t, "let = 1;")}, "expected next token to be IDENT"},
+		{"configurationDone", nil, "no program launched"},
+		{"stackTrace", StackTraceArguments{ThreadID: THREADID}, "the program is not paused"},
+		{"next", nil, "the program is not paused"},
+		{"pause", nil, "the program is not running"},
+		{"attach", nil, "unknown command attach"},
+	}
+
+	for _, tt := range tests {
+		msg := c.call(tt.command, tt.args, nil)
+		if msg.Success || !strings.Contains(msg.Message, tt.expected) {
+			t.Errorf("%s did not fail with %q. got=%+v", tt.command, tt.expected, msg)
+		}
+	}
+
+	c.request("launch", LaunchArguments{Program: writeProgram(t, "let x = 1;\nlen(x)")}, nil)
+	c.request("configurationDone", nil, nil)
+	var exited ExitedEvent
+	output := c.event("exited", &exited)
+	if output != "ERROR: argument to `len` not supported, got INTEGER\n" || exited.ExitCode != 1 {
+		t.Errorf("wrong end of a failing program. output=%q, exit code=%d", output, exited.ExitCode)
+	}
+	c.disconnect()
+
+	// a panic in the evaluator fails the program, not the adapter
+	c = startTest(t)
+	c.request("launch", LaunchArguments{Program: writeProgram(t, "5 / 0")}, nil)
+	c.request("configurationDone", nil, nil)
+	output = c.event("exited", &exited)
+	if !strings.Contains(output, "internal error") || exited.ExitCode != 1 {
+		t.Errorf("wrong end of a panicking program. output=%q, exit code=%d", output, exited.ExitCode)
+	}
+	c.disconnect()
+}
@@ -0,0 +1,152 @@
+package dap
+
+import "encoding/json"
+
+// request asks the adapter to run a command
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// response answers a request. Message is set when it failed
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// event tells the client something happened
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// InitializeArguments describes the client
+type InitializeArguments struct {
+	ClientID        string `json:"clientID,omitempty"`
+	AdapterID       string `json:"adapterID"`
+	LinesStartAt1   *bool  `json:"linesStartAt1,omitempty"`
+	ColumnsStartAt1 *bool  `json:"columnsStartAt1,omitempty"`
+}
+
+// LaunchArguments names the program to debug
+type LaunchArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry,omitempty"`
+	NoDebug     bool   `json:"noDebug,omitempty"`
+}
+
+// Source is a source file
+type Source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// SourceBreakpoint asks for a breakpoint on a line
+type SourceBreakpoint struct {
+	Line int `json:"line"`
+}
+
+// SetBreakpointsArguments replaces the breakpoints of a source
+type SetBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+// Breakpoint is a breakpoint as set by the adapter
+type Breakpoint struct {
+	Verified bool   `json:"verified"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Thread is a thread of the program. Monkey programs have one
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// StackTraceArguments selects frames of the call stack
+type StackTraceArguments struct {
+	ThreadID   int `json:"threadId"`
+	StartFrame int `json:"startFrame,omitempty"`
+	Levels     int `json:"levels,omitempty"` // 0 for all of them
+}
+
+// StackFrame is a frame of the call stack
+type StackFrame struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Source *Source `json:"source,omitempty"`
+	Line   int     `json:"line"`
+	Column int     `json:"column"`
+}
+
+// ScopesArguments selects a frame
+type ScopesArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+// Scope is a group of the variables of a frame
+type Scope struct {
+	Name               string `json:"name"`
+	PresentationHint   string `json:"presentationHint,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+// VariablesArguments selects the variables of a scope or the elements of
+// a collection
+type VariablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+// Variable is a variable or the element of a collection. Collections have
+// a VariablesReference to list their elements with
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// EvaluateArguments is an expression to evaluate in a frame, the
+// innermost one when FrameID is 0
+type EvaluateArguments struct {
+	Expression string `json:"expression"`
+	FrameID    int    `json:"frameId,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+// EvaluateResponse is the result of an evaluation
+type EvaluateResponse struct {
+	Result             string `json:"result"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// StoppedEvent tells the client the program paused
+type StoppedEvent struct {
+	Reason            string `json:"reason"`
+	ThreadID          int    `json:"threadId"`
+	AllThreadsStopped bool   `json:"allThreadsStopped"`
+}
+
+// OutputEvent carries output of the program
+type OutputEvent struct {
+	Category string `json:"category"`
+	Output   string `json:"output"`
+}
+
+// ExitedEvent tells the client the program finished
+type ExitedEvent struct {
+	ExitCode int `json:"exitCode"`
+}
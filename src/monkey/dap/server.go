@@ -0,0 +1,562 @@
+// Package dap implements a Debug Adapter Protocol server for Monkey, so
+// editors can run a program under the debugger, set breakpoints, step and
+// inspect the call stack and variables
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/debugger"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/pretty"
+	"monkey/wire"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// THREADID identifies the only thread of a Monkey program
+const THREADID = 1
+
+var errNotPaused = errors.New("the program is not paused")
+
+// server holds the state of one debug session
+type server struct {
+	writeMu sync.Mutex // guards out and seq, written to by the program too
+	out     io.Writer
+	seq     int
+
+	debugger *debugger.Debugger
+	program  *ast.Program
+	path     string
+	then     []func() // run once the response to a request is written
+
+	// added to the lines and columns of the client, 1 when it counts
+	// them from 0
+	lineOffset   int
+	columnOffset int
+
+	mu          sync.Mutex // guards stopped, terminating and frames
+	stopped     bool       // the program is paused, waiting to resume
+	terminating bool
+	frames      []*debugger.Frame // the call stack while paused
+
+	resumes chan debugger.Action
+	done    chan struct{} // closed when the program ends, nil before it starts
+
+	// what the variablesReference of a scope or collection lists, the
+	// reference being the index plus one. Reset each time the program
+	// resumes
+	references [][]debugger.Variable
+}
+
+type handler func(s *server, args json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":              (*server).initialize,
+	"launch":                  (*server).launch,
+	"setBreakpoints":          (*server).setBreakpoints,
+	"setExceptionBreakpoints": (*server).setExceptionBreakpoints,
+	"configurationDone":       (*server).configurationDone,
+	"threads":                 (*server).threads,
+	"stackTrace":              (*server).stackTrace,
+	"scopes":                  (*server).scopes,
+	"variables":               (*server).variables,
+	"continue":                (*server).continueRequest,
+	"next":                    (*server).next,
+	"stepIn":                  (*server).stepIn,
+	"stepOut":                 (*server).stepOut,
+	"pause":                   (*server).pause,
+	"evaluate":                (*server).evaluate,
+	"terminate":               (*server).terminate,
+	"disconnect":              (*server).disconnect,
+}
+
+// Serve answers the requests read from in, writing responses and events
+// to out, until the client disconnects. The program is stopped when Serve
+// returns
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{out: out, resumes: make(chan debugger.Action)}
+	s.debugger = debugger.New(s.paused)
+	defer s.stop()
+
+	r := bufio.NewReader(in)
+	for {
+		content, err := wire.Read(r)
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			if err := s.reply(&req, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(&req)
+		if err := s.reply(&req, body, err); err != nil {
+			return err
+		}
+
+		then := s.then
+		s.then = nil
+		for _, f := range then {
+			f()
+		}
+
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+// handle runs the handler for req. A panic in it fails only this request
+func (s *server) handle(req *request) (body interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			body, err = nil, fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	h, ok := handlers[req.Command]
+	if !ok {
+		return nil, fmt.Errorf("unknown command %s", req.Command)
+	}
+	return h(s, req.Arguments)
+}
+
+func (s *server) reply(req *request, body interface{}, err error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	resp := &response{Seq: s.seq, Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	return wire.Write(s.out, resp)
+}
+
+func (s *server) event(name string, body interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	return wire.Write(s.out, &event{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+func decode(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+// output sends what the program prints to the client
+type output struct {
+	s        *server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	if err := o.s.event("output", OutputEvent{Category: o.category, Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *server) initialize(args json.RawMessage) (interface{}, error) {
+	var a InitializeArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.LinesStartAt1 != nil && !*a.LinesStartAt1 {
+		s.lineOffset = 1
+	}
+	if a.ColumnsStartAt1 != nil && !*a.ColumnsStartAt1 {
+		s.columnOffset = 1
+	}
+
+	return map[string]interface{}{
+		"supportsConfigurationDoneRequest": true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+func (s *server) launch(args json.RawMessage) (interface{}, error) {
+	var a LaunchArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if s.program != nil {
+		return nil, errors.New("a program is already launched")
+	}
+	if a.Program == "" {
+		return nil, errors.New("no program to launch")
+	}
+
+	src, err := ioutil.ReadFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: %s", a.Program, strings.Join(p.Errors(), "; "))
+	}
+
+	s.program, s.path = program, filepath.Clean(a.Program)
+	s.debugger.StopOnEntry = a.StopOnEntry
+
+	// breakpoints can be set once the program is known
+	s.then = append(s.then, func() { s.event("initialized", nil) })
+	return nil, nil
+}
+
+func (s *server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a SetBreakpointsArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+
+	file, err := s.file(a.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	breakpoints := []Breakpoint{}
+	lines := []int{}
+	for _, b := range a.Breakpoints {
+		lines = append(lines, b.Line+s.lineOffset)
+		breakpoints = append(breakpoints, Breakpoint{Verified: true, Line: b.Line})
+	}
+	s.debugger.SetBreakpoints(file, lines)
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// file returns how the debugger names the source at path: "" for the
+// launched program, and the absolute path of a module otherwise
+func (s *server) file(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if program, err := filepath.Abs(s.path); err == nil && abs == program {
+		return "", nil
+	}
+	return abs, nil
+}
+
+func (s *server) setExceptionBreakpoints(args json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *server) configurationDone(args json.RawMessage) (interface{}, error) {
+	if s.program == nil {
+		return nil, errors.New("no program launched")
+	}
+	if s.done != nil {
+		return nil, errors.New("the program is already running")
+	}
+
+	s.then = append(s.then, s.start)
+	return nil, nil
+}
+
+// start runs the program, telling the client when it ends
+func (s *server) start() {
+	env := object.NewEnvironment()
+	env.SetOutput(&output{s: s, category: "stdout"})
+
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		result, stopped := s.debugger.Run(s.program, env)
+		if errObj, ok := result.(*object.Error); ok && !stopped {
+			exitCode = 1
+			s.event("output", OutputEvent{Category: "stderr", Output: errObj.Inspect() + "\n"})
+		}
+		s.event("exited", ExitedEvent{ExitCode: exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// paused tells the client the program stopped and waits for it to say how
+// to go on
+func (s *server) paused(d *debugger.Debugger, reason string) debugger.Action {
+	s.mu.Lock()
+	if s.terminating {
+		s.mu.Unlock()
+		return debugger.Abort
+	}
+	s.stopped, s.frames = true, d.Frames()
+	s.mu.Unlock()
+
+	s.event("stopped", StoppedEvent{Reason: reason, ThreadID: THREADID, AllThreadsStopped: true})
+	return <-s.resumes
+}
+
+// resume goes on running the paused program once the response is written
+func (s *server) resume(action debugger.Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		return errNotPaused
+	}
+	s.stopped, s.frames = false, nil
+	s.references = nil
+	s.then = append(s.then, func() { s.resumes <- action })
+	return nil
+}
+
+// stop aborts the program, if it started, and waits for it to end
+func (s *server) stop() {
+	s.mu.Lock()
+	if s.done == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.terminating = true
+	paused := s.stopped
+	s.stopped, s.frames = false, nil
+	s.mu.Unlock()
+
+	if paused {
+		s.resumes <- debugger.Abort
+	} else {
+		s.debugger.Pause() // the next statement aborts
+	}
+	<-s.done
+}
+
+// stack returns the call stack of the paused program, innermost first
+func (s *server) stack() ([]*debugger.Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		return nil, errNotPaused
+	}
+	return s.frames, nil
+}
+
+// frame returns the frame of the call stack with id, or the innermost one
+// for 0
+func (s *server) frame(id int) (*debugger.Frame, error) {
+	frames, err := s.stack()
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		id = 1
+	}
+	if id < 1 || id > len(frames) {
+		return nil, fmt.Errorf("no frame %d", id)
+	}
+	return frames[id-1], nil
+}
+
+func (s *server) threads(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{"threads": []Thread{{ID: THREADID, Name: "main"}}}, nil
+}
+
+func (s *server) stackTrace(args json.RawMessage) (interface{}, error) {
+	var a StackTraceArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frames, err := s.stack()
+	if err != nil {
+		return nil, err
+	}
+
+	program := &Source{Name: filepath.Base(s.path), Path: s.path}
+	stackFrames := []StackFrame{}
+	for i := a.StartFrame; i < len(frames); i++ {
+		if a.Levels > 0 && len(stackFrames) == a.Levels {
+			break
+		}
+		source := program
+		if file := frames[i].File; file != "" {
+			source = &Source{Name: filepath.Base(file), Path: file}
+		}
+		stackFrames = append(stackFrames, StackFrame{
+			ID:     i + 1,
+			Name:   frames[i].Name,
+			Source: source,
+			Line:   frames[i].Line - s.lineOffset,
+			Column: frames[i].Column - s.columnOffset,
+		})
+	}
+
+	return map[string]interface{}{"stackFrames": stackFrames, "totalFrames": len(frames)}, nil
+}
+
+func (s *server) scopes(args json.RawMessage) (interface{}, error) {
+	var a ScopesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	if frame.Call != nil {
+		scopes = append(scopes, Scope{
+			Name:               "Locals",
+			PresentationHint:   "locals",
+			VariablesReference: s.reference(debugger.Locals(frame.Env)),
+		})
+	}
+	scopes = append(scopes, Scope{Name: "Globals", VariablesReference: s.reference(debugger.Globals(frame.Env))})
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *server) variables(args json.RawMessage) (interface{}, error) {
+	var a VariablesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.references) {
+		return nil, fmt.Errorf("unknown variables reference %d", a.VariablesReference)
+	}
+
+	variables := []Variable{}
+	for _, v := range s.references[a.VariablesReference-1] {
+		variables = append(variables, Variable{
+			Name:               v.Name,
+			Value:              display(v.Value),
+			Type:               string(v.Value.Type()),
+			VariablesReference: s.reference(elements(v.Value)),
+		})
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+// reference returns a variablesReference listing variables, or 0 when
+// there are none
+func (s *server) reference(variables []debugger.Variable) int {
+	if len(variables) == 0 {
+		return 0
+	}
+	s.references = append(s.references, variables)
+	return len(s.references)
+}
+
+// elements returns the elements of a collection as variables named by
+// their index, key or field
+func elements(obj object.Object) []debugger.Variable {
+	variables := []debugger.Variable{}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		for i, e := range obj.Elements {
+			variables = append(variables, debugger.Variable{Name: strconv.Itoa(i), Value: e})
+		}
+	case *object.Hash:
+		for _, pair := range obj.SortedPairs() {
+			variables = append(variables, debugger.Variable{Name: pretty.Flat(pair.Key), Value: pair.Value})
+		}
+	case *object.Struct:
+		for _, name := range obj.StructType.Fields {
+			variables = append(variables, debugger.Variable{Name: name, Value: obj.Fields[name]})
+		}
+	}
+	return variables
+}
+
+// display returns the value of obj shown to the client, on one line.
+// Functions show their signature, but their whole source when nested in
+// a collection
+func display(obj object.Object) string {
+	if fn, ok := obj.(*object.Function); ok {
+		params := []string{}
+		for _, p := range fn.Parameters {
+			params = append(params, p.String())
+		}
+		return "fn " + fn.Name + "(" + strings.Join(params, ", ") + ")"
+	}
+	return strings.ReplaceAll(pretty.Flat(obj), "\n", " ")
+}
+
+func (s *server) continueRequest(args json.RawMessage) (interface{}, error) {
+	if err := s.resume(debugger.Continue); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"allThreadsContinued": true}, nil
+}
+
+func (s *server) next(args json.RawMessage) (interface{}, error) {
+	return nil, s.resume(debugger.StepOver)
+}
+
+func (s *server) stepIn(args json.RawMessage) (interface{}, error) {
+	return nil, s.resume(debugger.StepIn)
+}
+
+func (s *server) stepOut(args json.RawMessage) (interface{}, error) {
+	return nil, s.resume(debugger.StepOut)
+}
+
+func (s *server) pause(args json.RawMessage) (interface{}, error) {
+	if s.done == nil {
+		return nil, errors.New("the program is not running")
+	}
+	s.debugger.Pause()
+	return nil, nil
+}
+
+func (s *server) evaluate(args json.RawMessage) (interface{}, error) {
+	var a EvaluateArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.debugger.Eval(a.Expression, frame)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+	if result == nil {
+		return &EvaluateResponse{}, nil
+	}
+
+	return &EvaluateResponse{
+		Result:             display(result),
+		Type:               string(result.Type()),
+		VariablesReference: s.reference(elements(result)),
+	}, nil
+}
+
+func (s *server) terminate(args json.RawMessage) (interface{}, error) {
+	s.stop()
+	return nil, nil
+}
+
+func (s *server) disconnect(args json.RawMessage) (interface{}, error) {
+	s.stop()
+	return nil, nil
+}
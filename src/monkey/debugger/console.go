@@ -0,0 +1,225 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/pretty"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PROMPT prompts for a debugger command
+const PROMPT = "(debug) "
+
+// HELP describes the console commands
+const HELP = `break LINE, b LINE      set a breakpoint on LINE of the program
+break FILE:LINE         set a breakpoint on LINE of an imported module
+clear [FILE:]LINE       remove the breakpoint on LINE
+breakpoints             list the breakpoints
+continue, c             run to the next breakpoint
+step, s                 run to the next line, stepping into calls
+next, n                 run to the next line, stepping over calls
+out, o                  run until the current function returns
+backtrace, bt           print the call stack
+frame N, f N            select frame N of the backtrace
+locals                  print the local variables of the selected frame
+globals                 print the global variables
+print EXPR, p EXPR      evaluate EXPR in the selected frame
+list                    print the source around the current line
+quit, q                 stop the program
+`
+
+// console reads debugger commands from a user each time the program pauses
+type console struct {
+	in      *bufio.Scanner
+	out     io.Writer
+	printer *pretty.Printer
+	sources map[string][]string // the lines of each file shown, "" for the program
+
+	selected int // the frame commands apply to, 0 for the innermost
+}
+
+// Start runs program under a debugger controlled by commands read from in,
+// pausing before the first statement. source is the text of program, shown
+// when it pauses
+func Start(program *ast.Program, source string, in io.Reader, out io.Writer) {
+	c := &console{
+		in:      bufio.NewScanner(in),
+		out:     out,
+		printer: pretty.New(false),
+		sources: map[string][]string{"": strings.Split(source, "\n")},
+	}
+
+	env := object.NewEnvironment()
+	env.SetOutput(out)
+
+	d := New(c.paused)
+	d.StopOnEntry = true
+
+	result, stopped := d.Run(program, env)
+	switch {
+	case stopped:
+		io.WriteString(out, "program stopped\n")
+	case isError(result):
+		fmt.Fprintf(out, "program failed: %s\n", result.Inspect())
+	default:
+		io.WriteString(out, "program finished\n")
+	}
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+// paused shows where the program stopped and runs commands until one of
+// them resumes it
+func (c *console) paused(d *Debugger, reason string) Action {
+	c.selected = 0
+	frame := d.Frames()[0]
+	fmt.Fprintf(c.out, "stopped at %s, %s in %s\n", reason, where(frame.File, frame.Line), frame.Name)
+	c.printLine(frame.File, frame.Line)
+
+	for {
+		io.WriteString(c.out, PROMPT)
+		if !c.in.Scan() {
+			io.WriteString(c.out, "\n")
+			return Abort
+		}
+
+		if action, resume := c.command(d, strings.TrimSpace(c.in.Text())); resume {
+			return action
+		}
+	}
+}
+
+// command runs one command, returning how to go on if it resumes the
+// program
+func (c *console) command(d *Debugger, line string) (Action, bool) {
+	name, arg := line, ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch name {
+	case "":
+	case "continue", "c":
+		return Continue, true
+	case "step", "s":
+		return StepIn, true
+	case "next", "n":
+		return StepOver, true
+	case "out", "o":
+		return StepOut, true
+	case "quit", "q":
+		return Abort, true
+	case "break", "b", "clear":
+		file, line, err := parseLocation(arg)
+		if err != nil {
+			fmt.Fprintf(c.out, "usage: %s [FILE:]LINE\n", name)
+			break
+		}
+		d.SetBreakpoint(file, line, name != "clear")
+	case "breakpoints":
+		for _, b := range d.Breakpoints() {
+			fmt.Fprintln(c.out, where(b.File, b.Line))
+		}
+	case "backtrace", "bt":
+		for i, frame := range d.Frames() {
+			marker := " "
+			if i == c.selected {
+				marker = "*"
+			}
+			fmt.Fprintf(c.out, "%s %d: %s at %s\n", marker, i, frame.Name, where(frame.File, frame.Line))
+		}
+	case "frame", "f":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || n >= len(d.Frames()) {
+			fmt.Fprintf(c.out, "no frame %q\n", arg)
+			break
+		}
+		c.selected = n
+		frame := d.Frames()[n]
+		fmt.Fprintf(c.out, "%d: %s at %s\n", n, frame.Name, where(frame.File, frame.Line))
+		c.printLine(frame.File, frame.Line)
+	case "locals":
+		c.printVariables(Locals(d.Frames()[c.selected].Env))
+	case "globals":
+		c.printVariables(Globals(d.Frames()[c.selected].Env))
+	case "print", "p":
+		if arg == "" {
+			io.WriteString(c.out, "usage: print EXPR\n")
+			break
+		}
+		if result := d.Eval(arg, d.Frames()[c.selected]); result != nil {
+			io.WriteString(c.out, c.printer.Format(result)+"\n")
+		}
+	case "list":
+		frame := d.Frames()[c.selected]
+		for n := frame.Line - 3; n <= frame.Line+3; n++ {
+			c.printLine(frame.File, n)
+		}
+	case "help", "h":
+		io.WriteString(c.out, HELP)
+	default:
+		fmt.Fprintf(c.out, "unknown command %s, try help\n", name)
+	}
+
+	return Continue, false
+}
+
+// printLine prints a line of file with its number, reading the file the
+// first time one of its lines is shown
+func (c *console) printLine(file string, n int) {
+	lines, ok := c.sources[file]
+	if !ok {
+		source, _ := ioutil.ReadFile(file)
+		lines = strings.Split(string(source), "\n")
+		c.sources[file] = lines
+	}
+
+	if n >= 1 && n <= len(lines) {
+		fmt.Fprintf(c.out, "%4d  %s\n", n, lines[n-1])
+	}
+}
+
+// parseLocation parses a breakpoint location: LINE for the program, or
+// FILE:LINE for a module
+func parseLocation(arg string) (string, int, error) {
+	file := ""
+	if i := strings.LastIndexByte(arg, ':'); i >= 0 {
+		abs, err := filepath.Abs(arg[:i])
+		if err != nil {
+			return "", 0, err
+		}
+		file, arg = abs, arg[i+1:]
+	}
+
+	line, err := strconv.Atoi(arg)
+	if err == nil && line < 1 {
+		err = fmt.Errorf("invalid line %d", line)
+	}
+	return file, line, err
+}
+
+// where describes a line of file
+func where(file string, line int) string {
+	if file == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("line %d of %s", line, file)
+}
+
+func (c *console) printVariables(variables []Variable) {
+	if len(variables) == 0 {
+		io.WriteString(c.out, "no variables\n")
+	}
+	for _, v := range variables {
+		fmt.Fprintf(c.out, "%s = %s\n", v.Name, c.printer.Format(v.Value))
+	}
+}
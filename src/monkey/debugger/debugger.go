@@ -0,0 +1,334 @@
+// Package debugger pauses Monkey programs at breakpoints and steps through
+// them statement by statement, exposing the call stack and the variables of
+// each frame while paused
+package debugger
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action tells a paused program how to go on
+type Action int
+
+// Actions
+const (
+	Continue Action = iota // run to the next breakpoint
+	StepIn                 // stop at the next line, in a call it makes if any
+	StepOver               // stop at the next line of this frame or a caller
+	StepOut                // stop once this frame has returned
+	Abort                  // stop running the program
+)
+
+// Reasons for a pause
+const (
+	ENTRY      = "entry" // before the first statement, with StopOnEntry
+	BREAKPOINT = "breakpoint"
+	STEP       = "step"
+	PAUSE      = "pause" // requested with Pause
+)
+
+// Frame is a call in progress, the evaluation of an imported module, or the
+// program itself at the bottom of the stack
+type Frame struct {
+	Name string
+	Call *ast.CallExpression // nil for the program and modules
+	Env  *object.Environment // the environment of the running statement
+
+	// the position of the running statement. File is the absolute path of
+	// the module it is in, or "" for the program
+	File   string
+	Line   int
+	Column int
+}
+
+// Breakpoint is a line to pause at. File is the absolute path of a module,
+// or "" for the program
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+// Variable is a name bound in a frame
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Debugger observes a program run with Run. Each time the program pauses,
+// paused is called with the reason and returns how to go on. While paused
+// is running, the frames and variables may be inspected and expressions
+// evaluated in them
+type Debugger struct {
+	// StopOnEntry pauses the program before its first statement
+	StopOnEntry bool
+
+	paused func(d *Debugger, reason string) Action
+
+	mu          sync.Mutex // guards breakpoints and pause, set while running
+	breakpoints map[Breakpoint]bool
+	pause       bool
+
+	frames     []*Frame
+	action     Action
+	depth      int    // the number of frames when the last pause ended
+	file       string // the file the last pause was in
+	line       int    // the line the last pause was at
+	started    bool
+	evaluating bool
+}
+
+// aborted is panicked with to unwind a program stopped with Abort
+type aborted struct{}
+
+// New returns a debugger calling paused each time the program pauses
+func New(paused func(d *Debugger, reason string) Action) *Debugger {
+	return &Debugger{
+		paused:      paused,
+		breakpoints: make(map[Breakpoint]bool),
+		frames:      []*Frame{{Name: "<program>"}},
+	}
+}
+
+// Run evaluates node in env under the debugger, reporting whether the
+// program was aborted before it finished. A panic in the evaluator ends the
+// program with an internal error
+func (d *Debugger) Run(node ast.Node, env *object.Environment) (result object.Object, stopped bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(aborted); ok {
+				result, stopped = nil, true
+			} else {
+				result = internalError(r)
+			}
+			d.frames = d.frames[:1]
+		}
+		env.SetDebugger(nil)
+	}()
+
+	d.frames[0].Env = env
+	env.SetDebugger(d)
+	return evaluator.Eval(node, env), false
+}
+
+// SetBreakpoint sets or, when enabled is false, clears the breakpoint on a
+// line of file
+func (d *Debugger) SetBreakpoint(file string, line int, enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if enabled {
+		d.breakpoints[Breakpoint{file, line}] = true
+	} else {
+		delete(d.breakpoints, Breakpoint{file, line})
+	}
+}
+
+// SetBreakpoints replaces the breakpoints of file with ones on lines
+func (d *Debugger) SetBreakpoints(file string, lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for b := range d.breakpoints {
+		if b.File == file {
+			delete(d.breakpoints, b)
+		}
+	}
+	for _, line := range lines {
+		d.breakpoints[Breakpoint{file, line}] = true
+	}
+}
+
+// Breakpoints returns the breakpoints ordered by file and line, those of
+// the program first
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	breakpoints := []Breakpoint{}
+	for b := range d.breakpoints {
+		breakpoints = append(breakpoints, b)
+	}
+	sort.Slice(breakpoints, func(i, j int) bool {
+		if breakpoints[i].File != breakpoints[j].File {
+			return breakpoints[i].File < breakpoints[j].File
+		}
+		return breakpoints[i].Line < breakpoints[j].Line
+	})
+	return breakpoints
+}
+
+// Pause stops the program at the next statement it runs
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+// Frames returns the call stack, the innermost frame first
+func (d *Debugger) Frames() []*Frame {
+	frames := make([]*Frame, len(d.frames))
+	for i, frame := range d.frames {
+		frames[len(frames)-1-i] = frame
+	}
+	return frames
+}
+
+// Eval evaluates input in the environment of frame. The debugger ignores
+// the statements and calls it runs. A panic in the evaluator is returned as
+// an internal error
+func (d *Debugger) Eval(input string, frame *Frame) (result object.Object) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return &object.Error{Message: strings.Join(p.Errors(), "; ")}
+	}
+	if frame.Env == nil {
+		return &object.Error{Message: "frame " + frame.Name + " has not started"}
+	}
+
+	d.evaluating = true
+	defer func() {
+		d.evaluating = false
+		if r := recover(); r != nil {
+			result = internalError(r)
+		}
+	}()
+	return evaluator.Eval(program, frame.Env)
+}
+
+// internalError describes a panic recovered from the evaluator
+func internalError(r interface{}) *object.Error {
+	return &object.Error{
+		Message: fmt.Sprintf("internal error: %v", r),
+		Kind:    object.INTERNALERROR,
+	}
+}
+
+// Locals returns the variables bound in env and the environments enclosing
+// it, short of the global one, sorted by name. Inner bindings hide outer
+// ones of the same name
+func Locals(env *object.Environment) []Variable {
+	variables := []Variable{}
+	seen := map[string]bool{}
+
+	for ; env != nil && env.Outer() != nil; env = env.Outer() {
+		for _, name := range env.Names() {
+			if !seen[name] {
+				seen[name] = true
+				value, _ := env.Get(name)
+				variables = append(variables, Variable{Name: name, Value: value})
+			}
+		}
+	}
+
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables
+}
+
+// Globals returns the variables bound in the global environment of env
+func Globals(env *object.Environment) []Variable {
+	for env != nil && env.Outer() != nil {
+		env = env.Outer()
+	}
+	if env == nil {
+		return []Variable{}
+	}
+
+	variables := []Variable{}
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		variables = append(variables, Variable{Name: name, Value: value})
+	}
+	return variables
+}
+
+// Step is called by the evaluator before each node. The program pauses
+// before statements, at most once per line of a frame
+func (d *Debugger) Step(node ast.Node, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+
+	switch node.(type) {
+	case *ast.BlockStatement, *ast.FunctionStatement:
+		// blocks hold statements, and function declarations are hoisted
+		return
+	}
+	stmt, ok := node.(ast.Statement)
+	if !ok {
+		return
+	}
+
+	tok := ast.StatementToken(stmt)
+	frame := d.frames[len(d.frames)-1]
+	previous := frame.Line
+	frame.Env, frame.File, frame.Line, frame.Column = env, env.File(), tok.Line, tok.Column
+
+	reason := d.reason(frame.File, tok.Line, previous)
+	if reason == "" {
+		return
+	}
+
+	d.action = d.paused(d, reason)
+	d.depth, d.file, d.line = len(d.frames), frame.File, tok.Line
+	if d.action == Abort {
+		panic(aborted{})
+	}
+}
+
+// reason returns why the program pauses at a statement on a line of file,
+// after one on previous in the same frame, or "" when it runs on
+func (d *Debugger) reason(file string, line, previous int) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.started {
+		d.started = true
+		if d.StopOnEntry {
+			return ENTRY
+		}
+	}
+	if d.pause {
+		d.pause = false
+		return PAUSE
+	}
+
+	depth := len(d.frames)
+	moved := depth != d.depth || file != d.file || line != d.line
+	switch {
+	case d.action == StepIn && moved,
+		d.action == StepOver && depth <= d.depth && moved,
+		d.action == StepOut && depth < d.depth:
+		return STEP
+	}
+
+	if d.breakpoints[Breakpoint{file, line}] && line != previous {
+		return BREAKPOINT
+	}
+	return ""
+}
+
+// Call is called by the evaluator when a function call starts, and with a
+// nil node when an imported module starts being evaluated
+func (d *Debugger) Call(node *ast.CallExpression, name string) {
+	if d.evaluating {
+		return
+	}
+	d.frames = append(d.frames, &Frame{Name: name, Call: node})
+}
+
+// Return is called by the evaluator when a function call ends
+func (d *Debugger) Return(node *ast.CallExpression) {
+	if d.evaluating {
+		return
+	}
+	d.frames = d.frames[:len(d.frames)-1]
+}
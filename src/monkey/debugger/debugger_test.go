@@ -0,0 +1,281 @@
+package debugger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSource = `let total = 10;
+fn add(a, b) {
+    let sum = a + b;
+    return sum;
+}
+let x = add(1, 2);
+let y = add(x, total);
+puts(y);
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return program
+}
+
+// record runs testSource, answering each pause with the next of actions,
+// and returns where it paused as "reason line frames"
+func record(t *testing.T, breakpoints []int, actions ...Action) []string {
+	stops := []string{}
+	d := New(func(d *Debugger, reason string) Action {
+		frame := d.Frames()[0]
+		stops = append(stops, fmt.Sprintf("%s %d %d", reason, frame.Line, len(d.Frames())))
+		if len(stops) > len(actions) {
+			return Continue
+		}
+		return actions[len(stops)-1]
+	})
+	d.StopOnEntry = true
+	d.SetBreakpoints("", breakpoints)
+
+	env := object.NewEnvironment()
+	env.SetOutput(&bytes.Buffer{})
+	d.Run(parse(t, testSource), env)
+	return stops
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		breakpoints []int
+		actions     []Action
+		expected    []string
+	}{
+		{nil, []Action{Continue}, []string{"entry 1 1"}},
+		{nil, []Action{StepOver, StepOver, StepOver, StepOver},
+			[]string{"entry 1 1", "step 6 1", "step 7 1", "step 8 1"}},
+		{nil, []Action{StepOver, StepIn, StepIn, StepIn},
+			[]string{"entry 1 1", "step 6 1", "step 3 2", "step 4 2", "step 7 1"}},
+		{nil, []Action{StepOver, StepIn, StepOut, StepOver},
+			[]string{"entry 1 1", "step 6 1", "step 3 2", "step 7 1", "step 8 1"}},
+		{[]int{4}, []Action{Continue, Continue, Continue},
+			[]string{"entry 1 1", "breakpoint 4 2", "breakpoint 4 2"}},
+		{[]int{3, 8}, []Action{Continue, StepOver, Continue, Continue, Continue},
+			[]string{"entry 1 1", "breakpoint 3 2", "step 4 2", "breakpoint 3 2", "breakpoint 8 1"}},
+		{[]int{3}, []Action{StepOver, StepOver, StepOver},
+			[]string{"entry 1 1", "step 6 1", "breakpoint 3 2", "step 4 2", "breakpoint 3 2"}},
+		{nil, []Action{Abort}, []string{"entry 1 1"}},
+	}
+
+	for _, tt := range tests {
+		got := record(t, tt.breakpoints, tt.actions...)
+		if strings.Join(got, ", ") != strings.Join(tt.expected, ", ") {
+			t.Errorf("breakpoints %v, actions %v: wrong stops.\nexpected=%q\ngot=%q",
+				tt.breakpoints, tt.actions, tt.expected, got)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var locals, globals []Variable
+	var backtrace []string
+	var evaluated, missing object.Object
+
+	d := New(func(d *Debugger, reason string) Action {
+		frames := d.Frames()
+		for _, frame := range frames {
+			backtrace = append(backtrace, fmt.Sprintf("%s:%d", frame.Name, frame.Line))
+		}
+		locals = Locals(frames[0].Env)
+		globals = Globals(frames[0].Env)
+		evaluated = d.Eval("sum * total", frames[0])
+		missing = d.Eval("sum", frames[1])
+		return Abort
+	})
+	d.SetBreakpoint("", 4, true)
+
+	env := object.NewEnvironment()
+	result, stopped := d.Run(parse(t, testSource), env)
+	if !stopped || result != nil {
+		t.Errorf("program not aborted. got=%v %t", result, stopped)
+	}
+	if env.Debugger() != nil {
+		t.Errorf("debugger still attached after Run")
+	}
+
+	if strings.Join(backtrace, " ") != "add:4 <program>:6" {
+		t.Errorf("wrong backtrace. got=%q", backtrace)
+	}
+
+	got := []string{}
+	for _, v := range locals {
+		got = append(got, v.Name+"="+v.Value.Inspect())
+	}
+	if strings.Join(got, " ") != "a=1 b=2 sum=3" {
+		t.Errorf("wrong locals. got=%q", got)
+	}
+
+	got = []string{}
+	for _, v := range globals {
+		got = append(got, v.Name)
+	}
+	if strings.Join(got, " ") != "add total" {
+		t.Errorf("wrong globals. got=%q", got)
+	}
+
+	if integer, ok := evaluated.(*object.Integer); !ok || integer.Value != 30 {
+		t.Errorf("wrong evaluation in the paused frame. got=%v", evaluated)
+	}
+	if errObj, ok := missing.(*object.Error); !ok || errObj.Message != "identifier not found: sum" {
+		t.Errorf("caller frame sees callee locals. got=%v", missing)
+	}
+}
+
+func TestPanics(t *testing.T) {
+	var evaluated object.Object
+	d := New(func(d *Debugger, reason string) Action {
+		evaluated = d.Eval("1 / 0", d.Frames()[0])
+		return Continue
+	})
+	d.SetBreakpoint("", 2, true)
+
+	env := object.NewEnvironment()
+	result, stopped := d.Run(parse(t, "let f = fn() { 5 / 0 };\nf()"), env)
+	if errObj, ok := result.(*object.Error); stopped || !ok || errObj.Kind != object.INTERNALERROR {
+		t.Errorf("panic in the program not returned as an internal error. got=%v %t", result, stopped)
+	}
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Kind != object.INTERNALERROR {
+		t.Errorf("panic in an evaluation not returned as an internal error. got=%v", evaluated)
+	}
+	if len(d.Frames()) != 1 || env.Debugger() != nil {
+		t.Errorf("debugger not reset after a panic. frames=%d", len(d.Frames()))
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	module := filepath.Join(dir, "lib.monkey")
+	source := "let base = 100;\nfn offset(n) {\n    n + base\n}\n"
+	if err := ioutil.WriteFile(module, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stops := []string{}
+	d := New(func(d *Debugger, reason string) Action {
+		frames := []string{}
+		for _, frame := range d.Frames() {
+			frames = append(frames, fmt.Sprintf("%s@%s:%d", frame.Name, filepath.Base(frame.File), frame.Line))
+		}
+		stops = append(stops, reason+" "+strings.Join(frames, " "))
+		return Continue
+	})
+	d.SetBreakpoints(module, []int{1, 3})
+	d.SetBreakpoint("", 3, true)
+	d.SetBreakpoint("", 3, false)
+
+	expected := []Breakpoint{{"", 1}, {module, 1}, {module, 3}}
+	d.SetBreakpoint("", 1, true)
+	if got := d.Breakpoints(); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("wrong breakpoints.\nexpected=%v\ngot=%v", expected, got)
+	}
+	d.SetBreakpoints("", nil)
+
+	input := `let lib = import "` + module + `";
+let x = 1;
+let y = lib.offset(x);
+y`
+	env := object.NewEnvironment()
+	result, _ := d.Run(parse(t, input), env)
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 101 {
+		t.Errorf("wrong result. got=%v", result)
+	}
+
+	expectedStops := []string{
+		"breakpoint <module lib.monkey>@lib.monkey:1 <program>@.:1",
+		"breakpoint offset@lib.monkey:3 <program>@.:3",
+	}
+	if strings.Join(stops, ", ") != strings.Join(expectedStops, ", ") {
+		t.Errorf("wrong stops.\nexpected=%q\ngot=%q", expectedStops, stops)
+	}
+
+	// a run in a new environment imports the module afresh, stopping in its
+	// top-level code again
+	stops = nil
+	d.Run(parse(t, input), object.NewEnvironment())
+	if strings.Join(stops, ", ") != strings.Join(expectedStops, ", ") {
+		t.Errorf("wrong stops in a second run.\nexpected=%q\ngot=%q", expectedStops, stops)
+	}
+
+	// one that already imported it reuses the module
+	stops = nil
+	d.Run(parse(t, input), env)
+	if len(stops) != 1 || !strings.HasPrefix(stops[0], "breakpoint offset@") {
+		t.Errorf("reused module stopped in its top-level code. got=%q", stops)
+	}
+}
+
+func TestConsole(t *testing.T) {
+	input := strings.Join([]string{
+		"b 3",
+		"breakpoints",
+		"c",
+		"bt",
+		"locals",
+		"p a + b * 10",
+		"f 1",
+		"p x",
+		"clear 3",
+		"o",
+		"globals",
+		"nope",
+		"c",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(parse(t, testSource), testSource, strings.NewReader(input), &out)
+
+	expected := "stopped at entry, line 1 in <program>\n" +
+		"   1  let total = 10;\n" +
+		PROMPT + PROMPT + "line 3\n" +
+		PROMPT + "stopped at breakpoint, line 3 in add\n" +
+		"   3      let sum = a + b;\n" +
+		PROMPT + "* 0: add at line 3\n  1: <program> at line 6\n" +
+		PROMPT + "a = 1\nb = 2\n" +
+		PROMPT + "21\n" +
+		PROMPT + "1: <program> at line 6\n   6  let x = add(1, 2);\n" +
+		PROMPT + "ERROR: identifier not found: x\n" +
+		PROMPT + PROMPT + "stopped at step, line 7 in <program>\n" +
+		"   7  let y = add(x, total);\n" +
+		PROMPT + "add = fn add(a, b) {\nlet sum = (a + b);return sum;\n}\ntotal = 10\nx = 3\n" +
+		PROMPT + "unknown command nope, try help\n" +
+		PROMPT + "13\nprogram finished\n"
+	if out.String() != expected {
+		t.Errorf("wrong console output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+
+	out.Reset()
+	Start(parse(t, "let x = 1;\nlen(x)"), "", strings.NewReader("c\n"), &out)
+	if !strings.HasSuffix(out.String(), "program failed: ERROR: argument to `len` not supported, got INTEGER\n") {
+		t.Errorf("wrong output for a failing program. got=%q", out.String())
+	}
+
+	out.Reset()
+	Start(parse(t, testSource), testSource, strings.NewReader("q\n"), &out)
+	if !strings.HasSuffix(out.String(), "program stopped\n") {
+		t.Errorf("wrong output for a stopped program. got=%q", out.String())
+	}
+}
@@ -0,0 +1,162 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+func evalAssignExpression(
+	node *ast.AssignExpression,
+	env *object.Environment,
+) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		found, assigned := env.Assign(target.Value, value)
+		if !found {
+			return newError("identifier not found: " + target.Value)
+		}
+		if !assigned {
+			return newError("cannot assign to constant %s", target.Value)
+		}
+		return value
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		return evalIndexAssignment(left, index, value)
+
+	case *ast.MemberExpression:
+		obj := Eval(target.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		return evalMemberAssignment(obj, target.Member.Value, value)
+
+	default:
+		return newError("cannot assign to %s", node.Target)
+	}
+}
+
+func evalIndexAssignment(left, index, value object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if left.Frozen {
+			return newError("cannot modify frozen ARRAY")
+		}
+
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+
+		idx, ok := resolveIndex(integer.Value, len(left.Elements))
+		if !ok {
+			return newError("index out of range: %d", integer.Value)
+		}
+
+		if contains(value, left, map[object.Object]bool{}) {
+			return newError("cannot store ARRAY inside itself")
+		}
+
+		left.Elements[idx] = value
+		return value
+
+	case *object.Hash:
+		if left.Frozen {
+			return newError("cannot modify frozen HASH")
+		}
+
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		if contains(value, left, map[object.Object]bool{}) {
+			return newError("cannot store HASH inside itself")
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+		return value
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func evalMemberAssignment(obj object.Object, member string, value object.Object) object.Object {
+	instance, ok := obj.(*object.Struct)
+	if !ok {
+		return newError("member assignment not supported: %s.%s", obj.Type(), member)
+	}
+
+	if instance.Frozen {
+		return newError("cannot modify frozen %s", instance.StructType.Name)
+	}
+
+	if !instance.StructType.HasField(member) {
+		return newError("unknown field %s for struct %s", member, instance.StructType.Name)
+	}
+
+	if contains(value, instance, map[object.Object]bool{}) {
+		return newError("cannot store %s inside itself", instance.StructType.Name)
+	}
+
+	instance.Fields[member] = value
+	return value
+}
+
+// contains reports whether container is obj or is nested somewhere inside
+// it. Assignments use it to keep values acyclic, which printing and
+// encoding them rely on
+func contains(obj, container object.Object, seen map[object.Object]bool) bool {
+	if obj == container {
+		return true
+	}
+	if seen[obj] {
+		return false
+	}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		seen[obj] = true
+		for _, el := range obj.Elements {
+			if contains(el, container, seen) {
+				return true
+			}
+		}
+	case *object.Hash:
+		seen[obj] = true
+		for _, pair := range obj.Pairs {
+			if contains(pair.Value, container, seen) {
+				return true
+			}
+		}
+	case *object.Struct:
+		seen[obj] = true
+		for _, field := range obj.Fields {
+			if contains(field, container, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
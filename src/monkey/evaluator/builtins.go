@@ -2,7 +2,11 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
 	"monkey/object"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
@@ -14,7 +18,7 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
@@ -23,14 +27,38 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Print: func(out io.Writer, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(out, arg.Inspect())
 			}
 
 			return NULL
 		},
 	},
+	"str": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+	"freeze": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array, *object.Hash, *object.Struct:
+				freeze(arg)
+				return arg
+			default:
+				return newError("argument to `freeze` must be ARRAY, HASH or STRUCT, got %s", args[0].Type())
+			}
+		},
+	},
 	"first": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -106,3 +134,127 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 }
+
+// BuiltinNames returns the names of all builtin functions in sorted order
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// signatures describe the arguments of each builtin, written like Monkey
+// parameters with defaults for the optional ones
+var signatures = map[string]string{
+	"chr":         "chr(code)",
+	"contains":    "contains(s, substr)",
+	"ends_with":   "ends_with(s, suffix)",
+	"first":       "first(array)",
+	"format":      "format(template, values...)",
+	"format_int":  "format_int(n, base = 10)",
+	"freeze":      "freeze(value)",
+	"index_of":    "index_of(s, substr)",
+	"join":        "join(array, sep)",
+	"json_decode": "json_decode(s)",
+	"json_encode": "json_encode(value, indent = \"\")",
+	"last":        "last(array)",
+	"len":         "len(value)",
+	"lower":       "lower(s)",
+	"ord":         "ord(char)",
+	"parse_int":   "parse_int(s, base = 10)",
+	"printf":      "printf(template, values...)",
+	"push":        "push(array, value)",
+	"puts":        "puts(values...)",
+	"repeat":      "repeat(s, count)",
+	"replace":     "replace(s, old, new, n = -1)",
+	"rest":        "rest(array)",
+	"split":       "split(s, sep)",
+	"starts_with": "starts_with(s, prefix)",
+	"str":         "str(value)",
+	"substring":   "substring(s, start, end = len(s))",
+	"trim":        "trim(s, cutset = \" \\t\\n\")",
+	"upper":       "upper(s)",
+}
+
+// BuiltinSignature returns the signature of the builtin function name, such
+// as "len(value)"
+func BuiltinSignature(name string) (string, bool) {
+	signature, ok := signatures[name]
+	return signature, ok
+}
+
+// freeze marks obj and every array, hash or struct nested in it immutable
+func freeze(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if obj.Frozen {
+			return
+		}
+		obj.Frozen = true
+		for _, el := range obj.Elements {
+			freeze(el)
+		}
+	case *object.Hash:
+		if obj.Frozen {
+			return
+		}
+		obj.Frozen = true
+		for _, pair := range obj.Pairs {
+			freeze(pair.Value)
+		}
+	case *object.Struct:
+		if obj.Frozen {
+			return
+		}
+		obj.Frozen = true
+		for _, field := range obj.Fields {
+			freeze(field)
+		}
+	}
+}
+
+// checkArguments validates both the number and the types of args
+func checkArguments(
+	name string,
+	args []object.Object,
+	types ...object.ObjectType,
+) *object.Error {
+	if err := checkArity(args, len(types)); err != nil {
+		return err
+	}
+	return checkTypes(name, args, types...)
+}
+
+// checkArity reports an error unless len(args) is one of want
+func checkArity(args []object.Object, want ...int) *object.Error {
+	wanted := make([]string, len(want))
+	for i, n := range want {
+		if len(args) == n {
+			return nil
+		}
+		wanted[i] = fmt.Sprintf("%d", n)
+	}
+
+	return newError("wrong number of arguments. got=%d, want=%s",
+		len(args), strings.Join(wanted, " or "))
+}
+
+// checkTypes reports the first argument whose type differs from types
+func checkTypes(
+	name string,
+	args []object.Object,
+	types ...object.ObjectType,
+) *object.Error {
+	for i, arg := range args {
+		if i >= len(types) {
+			break
+		}
+		if arg.Type() != types[i] {
+			return newError("argument to `%s` must be %s, got %s",
+				name, types[i], arg.Type())
+		}
+	}
+	return nil
+}
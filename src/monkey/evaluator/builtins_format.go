@@ -0,0 +1,132 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/object"
+	"unicode/utf8"
+)
+
+func init() {
+	for name, builtin := range formatBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+var formatBuiltins = map[string]*object.Builtin{
+	"format": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			str, err := formatArguments("format", args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: str}
+		},
+	},
+	"printf": &object.Builtin{
+		Print: func(out io.Writer, args ...object.Object) object.Object {
+			str, err := formatArguments("printf", args)
+			if err != nil {
+				return err
+			}
+			io.WriteString(out, str)
+			return NULL
+		},
+	},
+}
+
+func formatArguments(name string, args []object.Object) (string, *object.Error) {
+	if len(args) < 1 {
+		return "", newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+	if err := checkTypes(name, args[:1], object.STRINGOBJ); err != nil {
+		return "", err
+	}
+
+	return formatString(name, args[0].(*object.String).Value, args[1:])
+}
+
+// formatString renders a printf-style format string, supporting the %d, %s,
+// %v, %q and %f verbs with optional flags, width and precision
+func formatString(name, format string, args []object.Object) (string, *object.Error) {
+	var out bytes.Buffer
+	argIdx := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format) && isFormatFlag(format[i]) {
+			i++
+		}
+		for i < len(format) && isDigit(format[i]) {
+			i++
+		}
+		if i < len(format) && format[i] == '.' {
+			i++
+			for i < len(format) && isDigit(format[i]) {
+				i++
+			}
+		}
+		if i >= len(format) {
+			return "", newError("incomplete verb at end of format string passed to `%s`", name)
+		}
+
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		spec := format[start:i] + string(verb)
+
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		if argIdx >= len(args) {
+			return "", newError("missing argument for %s in format string passed to `%s`", spec, name)
+		}
+		arg := args[argIdx]
+		argIdx++
+
+		switch verb {
+		case 'd':
+			integer, ok := arg.(*object.Integer)
+			if !ok {
+				return "", newError("%s in format string passed to `%s` requires INTEGER, got %s",
+					spec, name, arg.Type())
+			}
+			fmt.Fprintf(&out, spec, integer.Value)
+		case 'f':
+			if !isNumeric(arg) {
+				return "", newError("%s in format string passed to `%s` requires FLOAT or INTEGER, got %s",
+					spec, name, arg.Type())
+			}
+			fmt.Fprintf(&out, spec, toFloat(arg))
+		case 's', 'v':
+			fmt.Fprintf(&out, spec[:len(spec)-1]+"s", arg.Inspect())
+		case 'q':
+			fmt.Fprintf(&out, spec, arg.Inspect())
+		default:
+			return "", newError("unknown verb %s in format string passed to `%s`", spec, name)
+		}
+	}
+
+	if argIdx < len(args) {
+		return "", newError("too many arguments for format string passed to `%s`. got=%d, want=%d",
+			name, len(args), argIdx)
+	}
+
+	return out.String(), nil
+}
+
+func isFormatFlag(ch byte) bool {
+	return ch == '-' || ch == '+' || ch == ' ' || ch == '0' || ch == '#'
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+// maxJSONIndent is the most spaces json_encode indents each level by
+const maxJSONIndent = 16
+
+func init() {
+	for name, builtin := range jsonBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+var jsonBuiltins = map[string]*object.Builtin{
+	"json_encode": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArity(args, 1, 2); err != nil {
+				return err
+			}
+
+			indent := ""
+			if len(args) == 2 {
+				switch arg := args[1].(type) {
+				case *object.Integer:
+					if arg.Value < 0 {
+						return newError("negative indent passed to `json_encode`: %d", arg.Value)
+					}
+					if arg.Value > maxJSONIndent {
+						return newError("indent passed to `json_encode` is over %d: %d", maxJSONIndent, arg.Value)
+					}
+					indent = strings.Repeat(" ", int(arg.Value))
+				case *object.String:
+					indent = arg.Value
+				default:
+					return newError("argument to `json_encode` must be INTEGER or STRING, got %s",
+						args[1].Type())
+				}
+			}
+
+			value, errObj := toJSONValue(args[0])
+			if errObj != nil {
+				return errObj
+			}
+
+			var out bytes.Buffer
+			enc := json.NewEncoder(&out)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", indent)
+			if err := enc.Encode(value); err != nil {
+				return newError("could not encode JSON: %s", err)
+			}
+
+			return &object.String{Value: strings.TrimSuffix(out.String(), "\n")}
+		},
+	},
+	"json_decode": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("json_decode", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			dec := json.NewDecoder(strings.NewReader(args[0].(*object.String).Value))
+			dec.UseNumber()
+
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return newError("invalid JSON: %s", err)
+			}
+			if _, err := dec.Token(); err != io.EOF {
+				return newError("invalid JSON: unexpected data after top-level value")
+			}
+
+			return fromJSONValue(value)
+		},
+	},
+}
+
+func toJSONValue(obj object.Object) (interface{}, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return nil, newError("cannot encode %s as JSON", obj.Inspect())
+		}
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := toJSONValue(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		members := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			var key string
+			switch k := pair.Key.(type) {
+			case *object.String:
+				key = k.Value
+			case *object.Integer:
+				key = strconv.FormatInt(k.Value, 10)
+			default:
+				return nil, newError("cannot encode %s as JSON object key", pair.Key.Type())
+			}
+			if _, ok := members[key]; ok {
+				return nil, newError("duplicate JSON object key %q", key)
+			}
+
+			value, err := toJSONValue(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			members[key] = value
+		}
+		return members, nil
+	default:
+		return nil, newError("cannot encode %s as JSON", obj.Type())
+	}
+}
+
+func fromJSONValue(value interface{}) object.Object {
+	switch value := value.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return nativeBoolToBooleanObject(value)
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return &object.Integer{Value: i}
+		}
+		f, _ := value.Float64()
+		return &object.Float{Value: f}
+	case string:
+		return &object.String{Value: value}
+	case []interface{}:
+		elements := make([]object.Object, len(value))
+		for i, el := range value {
+			elements[i] = fromJSONValue(el)
+		}
+		return &object.Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair, len(value))
+		for k, v := range value {
+			key := &object.String{Value: k}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: fromJSONValue(v)}
+		}
+		return &object.Hash{Pairs: pairs}
+	default:
+		return newError("unsupported JSON value: %T", value)
+	}
+}
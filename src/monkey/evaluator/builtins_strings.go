@@ -0,0 +1,283 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxStringLength limits the bytes in a string that a builtin builds from a
+// count, so a huge count is an error instead of exhausting memory
+var MaxStringLength = 1 << 30
+
+func init() {
+	for name, builtin := range stringBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+var stringBuiltins = map[string]*object.Builtin{
+	"split": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("split", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			sep := args[1].(*object.String).Value
+
+			parts := strings.Split(s, sep)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"join": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("join", args, object.ARRAYOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			arr := args[0].(*object.Array)
+			sep := args[1].(*object.String).Value
+
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError("elements passed to `join` must be STRING, got %s", el.Type())
+				}
+				parts[i] = str.Value
+			}
+
+			return &object.String{Value: strings.Join(parts, sep)}
+		},
+	},
+	"trim": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArity(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkTypes("trim", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			if len(args) == 1 {
+				return &object.String{Value: strings.TrimSpace(s)}
+			}
+
+			cutset := args[1].(*object.String).Value
+			return &object.String{Value: strings.Trim(s, cutset)}
+		},
+	},
+	"upper": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("upper", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+		},
+	},
+	"lower": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("lower", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+		},
+	},
+	"contains": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("contains", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			substr := args[1].(*object.String).Value
+			return nativeBoolToBooleanObject(strings.Contains(s, substr))
+		},
+	},
+	"index_of": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("index_of", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			substr := args[1].(*object.String).Value
+
+			idx := strings.Index(s, substr)
+			if idx < 0 {
+				return &object.Integer{Value: -1}
+			}
+
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[:idx]))}
+		},
+	},
+	"replace": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArity(args, 3, 4); err != nil {
+				return err
+			}
+			if err := checkTypes("replace", args,
+				object.STRINGOBJ, object.STRINGOBJ, object.STRINGOBJ, object.INTEGEROBJ); err != nil {
+				return err
+			}
+
+			n := int64(-1)
+			if len(args) == 4 {
+				n = args[3].(*object.Integer).Value
+			}
+
+			s := args[0].(*object.String).Value
+			old := args[1].(*object.String).Value
+			new := args[2].(*object.String).Value
+			return &object.String{Value: strings.Replace(s, old, new, int(n))}
+		},
+	},
+	"starts_with": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("starts_with", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			prefix := args[1].(*object.String).Value
+			return nativeBoolToBooleanObject(strings.HasPrefix(s, prefix))
+		},
+	},
+	"ends_with": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("ends_with", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			suffix := args[1].(*object.String).Value
+			return nativeBoolToBooleanObject(strings.HasSuffix(s, suffix))
+		},
+	},
+	"repeat": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("repeat", args, object.STRINGOBJ, object.INTEGEROBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			count := args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError("negative count passed to `repeat`: %d", count)
+			}
+			if s != "" && count > int64(MaxStringLength/len(s)) {
+				return newError("result of `repeat` would be longer than %d bytes", MaxStringLength)
+			}
+
+			return &object.String{Value: strings.Repeat(s, int(count))}
+		},
+	},
+	"substring": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArity(args, 2, 3); err != nil {
+				return err
+			}
+			if err := checkTypes("substring", args,
+				object.STRINGOBJ, object.INTEGEROBJ, object.INTEGEROBJ); err != nil {
+				return err
+			}
+
+			var end *int64
+			if len(args) == 3 {
+				end = &args[2].(*object.Integer).Value
+			}
+
+			runes := []rune(args[0].(*object.String).Value)
+			start := args[1].(*object.Integer).Value
+			from, to := sliceBounds(int64(len(runes)), &start, end)
+
+			return &object.String{Value: string(runes[from:to])}
+		},
+	},
+	"ord": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("ord", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			if utf8.RuneCountInString(s) != 1 {
+				return newError("argument to `ord` must be a single character, got %q", s)
+			}
+
+			r, _ := utf8.DecodeRuneInString(s)
+			return &object.Integer{Value: int64(r)}
+		},
+	},
+	"chr": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("chr", args, object.INTEGEROBJ); err != nil {
+				return err
+			}
+
+			code := args[0].(*object.Integer).Value
+			if code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+				return newError("invalid character code passed to `chr`: %d", code)
+			}
+
+			return &object.String{Value: string(rune(code))}
+		},
+	},
+	"parse_int": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArity(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkTypes("parse_int", args, object.STRINGOBJ, object.INTEGEROBJ); err != nil {
+				return err
+			}
+
+			base := int64(10)
+			if len(args) == 2 {
+				base = args[1].(*object.Integer).Value
+			}
+
+			if base < 2 || base > 36 {
+				return newError("invalid base passed to `parse_int`: %d", base)
+			}
+
+			s := strings.TrimSpace(args[0].(*object.String).Value)
+			value, err := strconv.ParseInt(s, int(base), 64)
+			if err != nil {
+				return newError("could not parse %q as integer", s)
+			}
+
+			return &object.Integer{Value: value}
+		},
+	},
+	"format_int": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArity(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkTypes("format_int", args, object.INTEGEROBJ, object.INTEGEROBJ); err != nil {
+				return err
+			}
+
+			base := int64(10)
+			if len(args) == 2 {
+				base = args[1].(*object.Integer).Value
+			}
+
+			if base < 2 || base > 36 {
+				return newError("invalid base passed to `format_int`: %d", base)
+			}
+
+			value := args[0].(*object.Integer).Value
+			return &object.String{Value: strconv.FormatInt(value, int(base))}
+		},
+	},
+}
@@ -1,9 +1,11 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/ast"
 	"monkey/object"
+	"monkey/token"
 )
 
 // Native object
@@ -13,8 +15,20 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// MaxCallDepth limits how deeply function calls may nest before evaluation
+// stops with an InternalError
+var MaxCallDepth = 10000
+
+// InternalErrorsUncatchable keeps errors of kind InternalError, such as
+// exceeding MaxCallDepth, from being handled by try/catch
+var InternalErrorsUncatchable = true
+
 // Eval evaluates the ast node tree to return the correct object
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if debugger := env.Debugger(); debugger != nil {
+		debugger.Step(node, env)
+	}
+
 	switch node := node.(type) {
 
 	// Statements
@@ -34,12 +48,33 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
+
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Pattern != nil {
+			if err := destructure(node.Pattern, val, env, node.Token.Type == token.CONST); err != nil {
+				return err
+			}
+			return nil
+		}
+		if !env.Define(node.Name.Value, val, node.Token.Type == token.CONST) {
+			return newError("cannot redeclare constant %s", node.Name.Value)
+		}
+
+	case *ast.FunctionStatement:
+		if !env.Define(node.Name.Value, Eval(node.Function, env), false) {
+			return newError("cannot redeclare constant %s", node.Name.Value)
+		}
+
+	case *ast.StructStatement:
+		if !env.Define(node.Name.Value, evalStructStatement(node, env), false) {
+			return newError("cannot redeclare constant %s", node.Name.Value)
+		}
 
 	// Expressions
 	case *ast.IntegerLiteral:
@@ -48,6 +83,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
@@ -79,7 +117,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Env: env, Body: body}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -91,7 +129,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		debugger := env.Debugger()
+		if debugger != nil {
+			debugger.Call(node, callName(node, function))
+		}
+		result := applyFunction(function, args, env)
+		if debugger != nil {
+			debugger.Return(node)
+		}
+
+		if errObj, ok := result.(*object.Error); ok {
+			errObj.Stack = append(errObj.Stack, callFrame(node, function))
+		}
+		return result
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -113,6 +163,31 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
+	case *ast.ImportExpression:
+		return evalImportExpression(node, env)
+
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
+	case *ast.StructLiteral:
+		return evalStructLiteral(node, env)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Member.Value)
 	}
 
 	return nil
@@ -124,8 +199,16 @@ func evalProgram(
 ) object.Object {
 	var result object.Object
 
+	if err := hoistFunctions(program.Statements, env); err != nil {
+		return err
+	}
+
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		if stmt, ok := statement.(*ast.FunctionStatement); ok {
+			result = evalHoistedFunction(stmt, env)
+		} else {
+			result = Eval(statement, env)
+		}
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -144,8 +227,16 @@ func evalBlockStatements(
 ) object.Object {
 	var result object.Object
 
+	if err := hoistFunctions(block.Statements, env); err != nil {
+		return err
+	}
+
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		if stmt, ok := statement.(*ast.FunctionStatement); ok {
+			result = evalHoistedFunction(stmt, env)
+		} else {
+			result = Eval(statement, env)
+		}
 
 		if result != nil {
 			rt := result.Type()
@@ -158,6 +249,31 @@ func evalBlockStatements(
 	return result
 }
 
+// hoistFunctions binds every function declared in statements before any of
+// them run, so declarations can call each other regardless of order
+func hoistFunctions(statements []ast.Statement, env *object.Environment) *object.Error {
+	for _, statement := range statements {
+		if stmt, ok := statement.(*ast.FunctionStatement); ok {
+			if !env.Define(stmt.Name.Value, Eval(stmt.Function, env), false) {
+				return newError("cannot redeclare constant %s", stmt.Name.Value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// evalHoistedFunction evaluates a declaration that hoistFunctions already
+// bound. It leaves the name alone, so assignments made before it was reached
+// stand, but still refuses a constant declared under the same name after
+// hoisting
+func evalHoistedFunction(stmt *ast.FunctionStatement, env *object.Environment) object.Object {
+	if env.IsConst(stmt.Name.Value) {
+		return newError("cannot redeclare constant %s", stmt.Name.Value)
+	}
+	return nil
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -183,6 +299,8 @@ func evalInfixExpression(
 	switch {
 	case left.Type() == object.INTEGEROBJ && right.Type() == object.INTEGEROBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumeric(left) && isNumeric(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -210,11 +328,12 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGEROBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalIntegerInfixExpression(
@@ -246,6 +365,37 @@ func evalIntegerInfixExpression(
 	}
 }
 
+// evalFloatInfixExpression compares a float with another number. Floats
+// only come from decoding JSON, so they support no arithmetic
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(toFloat(left) == toFloat(right))
+	case "!=":
+		return nativeBoolToBooleanObject(toFloat(left) != toFloat(right))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumeric(obj object.Object) bool {
+	return obj.Type() == object.INTEGEROBJ || obj.Type() == object.FLOATOBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -279,7 +429,10 @@ func evalIfExpression(
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return &object.Error{
+		Message: fmt.Sprintf(format, a...),
+		Kind:    object.RUNTIMEERROR,
+	}
 }
 
 func isError(obj object.Object) bool {
@@ -324,13 +477,26 @@ func evalExpressions(
 func applyFunction(
 	fn object.Object,
 	args []object.Object,
+	env *object.Environment,
 ) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		if env.Depth() >= MaxCallDepth {
+			return &object.Error{
+				Message: fmt.Sprintf("maximum call depth of %d exceeded", MaxCallDepth),
+				Kind:    object.INTERNALERROR,
+			}
+		}
+		extendedEnv, err := extendFunctionEnv(fn, args, env)
+		if err != nil {
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
+		if fn.Print != nil {
+			return fn.Print(env.Output(), args...)
+		}
 		return fn.Fn(args...)
 	default:
 		return newError("not a function: %s", fn.Type())
@@ -340,14 +506,58 @@ func applyFunction(
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	caller *object.Environment,
+) (*object.Environment, *object.Error) {
+	required := 0
+	for _, param := range fn.Parameters {
+		if _, ok := param.(*ast.DefaultPattern); !ok {
+			required++
+		}
+	}
+
+	want := []int{}
+	for n := required; n <= len(fn.Parameters); n++ {
+		want = append(want, n)
+	}
+	if err := checkArity(args, want...); err != nil {
+		return nil, err
+	}
+
+	env := object.NewCallEnvironment(fn.Env, caller)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		var arg object.Object
+		if paramIdx < len(args) {
+			arg = args[paramIdx]
+		}
+
+		if err := destructure(param, arg, env, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
+// callFrame describes a call for the stack of an error passing through it
+func callFrame(node *ast.CallExpression, function object.Object) string {
+	return fmt.Sprintf("%s (line %d, column %d)",
+		callName(node, function), node.Token.Line, node.Token.Column)
+}
+
+// callName names the function called by node, "fn" for anonymous ones
+func callName(node *ast.CallExpression, function object.Object) string {
+	if fn, ok := function.(*object.Function); ok && fn.Name != "" {
+		return fn.Name
 	}
 
-	return env
+	switch function := node.Function.(type) {
+	case *ast.Identifier:
+		return function.Value
+	case *ast.MemberExpression:
+		return function.Member.Value
+	}
+	return "fn"
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -372,18 +582,55 @@ func evalStringInfixExpression(
 	return &object.String{Value: leftVal + rightVal}
 }
 
+func evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-
-	if idx < 0 || idx > max {
+	idx, ok := resolveIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
 		return NULL
 	}
 
 	return arrayObject.Elements[idx]
 }
 
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := resolveIndex(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+// resolveIndex turns a possibly negative index into an offset into a
+// sequence of the given length, reporting whether it is in range
+func resolveIndex(idx int64, length int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+	return idx, true
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
@@ -404,20 +651,80 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAYOBJ && index.Type() == object.INTEGEROBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRINGOBJ && index.Type() == object.INTEGEROBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASHOBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULEOBJ:
+		return evalHashIndexExpression(left.(*object.Module).Members, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+func evalSliceExpression(
+	node *ast.SliceExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	start, err := evalSliceBound(node.Start, env)
+	if err != nil {
+		return err
+	}
+	end, err := evalSliceBound(node.End, env)
+	if err != nil {
+		return err
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		from, to := sliceBounds(int64(len(left.Elements)), start, end)
+		elements := make([]object.Object, to-from)
+		copy(elements, left.Elements[from:to])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		from, to := sliceBounds(int64(len(runes)), start, end)
+		return &object.String{Value: string(runes[from:to])}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+func evalSliceBound(
+	node ast.Expression,
+	env *object.Environment,
+) (*int64, *object.Error) {
+	if node == nil {
+		return nil, nil
+	}
+
+	bound := Eval(node, env)
+	if errObj, ok := bound.(*object.Error); ok {
+		return nil, errObj
+	}
+
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return nil, newError("slice bound must be INTEGER, got %s", bound.Type())
+	}
+
+	return &integer.Value, nil
+}
+
 func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
+
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -439,3 +746,126 @@ func evalHashLiteral(
 
 	return &object.Hash{Pairs: pairs}
 }
+
+// sliceBounds resolves optional start and end positions against length,
+// counting negative positions from the end and clamping to [0, length]
+func sliceBounds(length int64, start, end *int64) (int64, int64) {
+	from, to := int64(0), length
+
+	if start != nil {
+		from = clampIndex(*start, length)
+	}
+	if end != nil {
+		to = clampIndex(*end, length)
+	}
+	if to < from {
+		to = from
+	}
+
+	return from, to
+}
+
+func clampIndex(idx, length int64) int64 {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		return 0
+	}
+	if idx > length {
+		return length
+	}
+	return idx
+}
+
+func evalThrowStatement(
+	node *ast.ThrowStatement,
+	env *object.Environment,
+) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	errObj := &object.Error{Message: val.Inspect(), Kind: object.THROWNERROR, Value: val}
+
+	if hash, ok := val.(*object.Hash); ok {
+		if message, ok := hashGet(hash, "message"); ok {
+			errObj.Message = message.Inspect()
+		}
+	}
+
+	return errObj
+}
+
+func evalTryExpression(
+	node *ast.TryExpression,
+	env *object.Environment,
+) object.Object {
+	result := Eval(node.Block, env)
+
+	if errObj, ok := result.(*object.Error); ok && node.Catch != nil && isCatchable(errObj) {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if node.Param != nil {
+			catchEnv.Set(node.Param.Value, errorToHash(errObj))
+		}
+		result = Eval(node.Catch, catchEnv)
+	}
+
+	if node.Finally != nil {
+		finally := Eval(node.Finally, env)
+		if finally != nil {
+			ft := finally.Type()
+			if ft == object.RETURNVALUEOBJ || ft == object.ERROROBJ {
+				return finally
+			}
+		}
+	}
+
+	return result
+}
+
+func isCatchable(err *object.Error) bool {
+	return !InternalErrorsUncatchable || err.Kind != object.INTERNALERROR
+}
+
+// errorToHash builds the value a catch block receives for err. Its value
+// is the object that was thrown, or null for runtime errors. The kind of a
+// thrown hash comes from the hash, which only the catch block sees: the
+// error itself keeps THROWNERROR, so no thrown value can pass for an
+// internal error
+func errorToHash(err *object.Error) *object.Hash {
+	kind := err.Kind
+	if hash, ok := err.Value.(*object.Hash); ok && err.Kind == object.THROWNERROR {
+		if thrown, ok := hashGet(hash, "kind"); ok {
+			kind = thrown.Inspect()
+		}
+	}
+
+	stack := make([]object.Object, len(err.Stack))
+	for i, frame := range err.Stack {
+		stack[i] = &object.String{Value: frame}
+	}
+
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	hashSet(hash, "message", &object.String{Value: err.Message})
+	hashSet(hash, "kind", &object.String{Value: kind})
+	hashSet(hash, "stack", &object.Array{Elements: stack})
+	if err.Value != nil {
+		hashSet(hash, "value", err.Value)
+	} else {
+		hashSet(hash, "value", NULL)
+	}
+
+	return hash
+}
+
+func hashGet(hash *object.Hash, key string) (object.Object, bool) {
+	pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+	return pair.Value, ok
+}
+
+func hashSet(hash *object.Hash, key string, value object.Object) {
+	k := &object.String{Value: key}
+	hash.Pairs[k.HashKey()] = object.HashPair{Key: k, Value: value}
+}
@@ -1,9 +1,15 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -246,6 +252,142 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn square(x) { x * x } square(4)", 16},
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)", 120},
+		{"let r = twice(3); fn twice(x) { x * 2 }; r", 6},
+		{`fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+even(10)`, true},
+		{`let f = fn() { let r = helper(); fn helper() { 41 }; r + 1 }; f()`, 42},
+		{"fn shadow() { 1 }; fn shadow() { 2 }; shadow()", 2},
+		{"f = 5; fn f() { 1 }; f", 5},
+		{"let g = fn() { f = 3; fn f() { 1 }; f }; g()", 3},
+		{"let f = 5; fn f() { 1 }; f", 5},
+		{"fn named(a, b) { a }; named", inspected("fn named(a, b) {\na\n}")},
+		{"let alias = fn(x) { x }; alias", inspected("fn alias(x) {\nx\n}")},
+		{"let alias = fn(x) { x }; let other = alias; other", inspected("fn alias(x) {\nx\n}")},
+		{"fn(x) { x }", inspected("fn(x) {\nx\n}")},
+		{"|x| x", inspected("fn(x) {\nx\n}")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+
+	for _, input := range []string{"fn named(a, b) { a }", "1; fn named(a, b) { a }"} {
+		if evaluated := testEval(input); evaluated != nil {
+			t.Errorf("%q: declaration evaluated to %T (%+v), want nil", input, evaluated, evaluated)
+		}
+	}
+
+	input := `fn inner() { throw "deep" }
+let callback = inner;
+fn outer() { callback() }
+try { outer() } catch (e) { e["stack"] }`
+
+	evaluated := testEval(input)
+	stack, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{
+		"inner (line 3, column 22)",
+		"outer (line 4, column 12)",
+	}
+	if len(stack.Elements) != len(expected) {
+		t.Fatalf("wrong stack length. want=%d, got=%d", len(expected), len(stack.Elements))
+	}
+	for i, frame := range expected {
+		testStringObject(t, stack.Elements[i], frame)
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; let y = 1; x = y = 5; x + y", 10},
+		{"let x = 1; let f = fn() { x = x + 1 }; f(); f(); x", 3},
+		{"let x = 1; let f = fn(x) { x = 10 }; f(1); x", 1},
+		{"let xs = [1, 2, 3]; xs[0] = 10; xs[-1] = 30; xs", inspected("[10, 2, 30]")},
+		{`let h = {"a": 1}; h["a"] = 2; h["b"] = 3; [h["a"], h["b"]]`, inspected("[2, 3]")},
+		{"struct P { x }; let p = P { x: 1 }; p.x = 5; p.x", 5},
+		{"let x = 1; (x = 2) + 1", 3},
+		{"y = 1", errorMessage("identifier not found: y")},
+		{"let xs = [1]; xs[1] = 2", errorMessage("index out of range: 1")},
+		{`let xs = [1]; xs["a"] = 2`, errorMessage("array index must be INTEGER, got STRING")},
+		{`let h = {}; h[fn(x) { x }] = 1`, errorMessage("unusable as hash key: FUNCTION")},
+		{`let s = "abc"; s[0] = "x"`, errorMessage("index assignment not supported: STRING")},
+		{"struct P { x }; let p = P { x: 1 }; p.y = 5", errorMessage("unknown field y for struct P")},
+		{"let x = 1; x.y = 5", errorMessage("member assignment not supported: INTEGER.y")},
+		{"let xs = [1]; xs[0] = xs", errorMessage("cannot store ARRAY inside itself")},
+		{`let xs = [1]; xs[0] = {"k": [xs]}`, errorMessage("cannot store ARRAY inside itself")},
+		{`let h = {}; h["self"] = h`, errorMessage("cannot store HASH inside itself")},
+		{`let h = {"a": []}; let inner = h["a"]; inner[0] = h`, errorMessage("index out of range: 0")},
+		{`let h = {"a": [0]}; let inner = h["a"]; inner[0] = h`, errorMessage("cannot store ARRAY inside itself")},
+		{"struct Node { next }; let n = Node { next: 0 }; n.next = n", errorMessage("cannot store Node inside itself")},
+		{"struct Node { next }; let n = Node { next: 0 }; n.next = [n]", errorMessage("cannot store Node inside itself")},
+		{"let xs = [1]; try { xs[0] = xs } catch { 0 }; xs", inspected("[1]")},
+		{"let a = [1]; let b = [a, a]; a[0] = 2; b", inspected("[[2], [2]]")},
+		{"struct Node { next }; let a = Node { next: 0 }; let b = Node { next: a }; a.next = 5; b.next.next", 5},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestConstAndFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const x = 5; x", 5},
+		{"const x = 5; let f = fn() { let x = 10; x }; f() + x", 15},
+		{"const x = 5; let f = fn(x) { x * 2 }; f(2)", 4},
+		{"let x = 1; const x = 2; x", 2},
+		{"const xs = [1]; push(xs, 2)", inspected("[1, 2]")},
+		{"let xs = freeze([1, [2]]); xs", inspected("[1, [2]]")},
+		{`let h = freeze({"a": [1]}); h["a"][0]`, 1},
+		{"let xs = [1]; freeze(xs); let ys = push(xs, 2); ys[0] = 5; ys", inspected("[5, 2]")},
+		{"const x = 5; x = 6", errorMessage("cannot assign to constant x")},
+		{"const x = 5; let f = fn() { x = 6 }; f()", errorMessage("cannot assign to constant x")},
+		{"const x = 5; let x = 6", errorMessage("cannot redeclare constant x")},
+		{"const x = 5; const x = 6", errorMessage("cannot redeclare constant x")},
+		{"const x = 5; let [x] = [6]", errorMessage("cannot redeclare constant x")},
+		{"const [a, b] = [1, 2]; a + b", 3},
+		{`const {"k": [k, ...rest]} = {"k": [1, 2]}; k + len(rest)`, 2},
+		{"const [a, b] = [1, 2]; a = 3", errorMessage("cannot assign to constant a")},
+		{"const [a, b] = [1, 2]; let b = 3", errorMessage("cannot redeclare constant b")},
+		{"const [a] = [1]; const [a] = [2]", errorMessage("cannot redeclare constant a")},
+		{"const f = 5; fn f() { 1 }", errorMessage("cannot redeclare constant f")},
+		{"const P = 5; struct P { x }", errorMessage("cannot redeclare constant P")},
+		{"let xs = freeze([1]); xs[0] = 2", errorMessage("cannot modify frozen ARRAY")},
+		{"let xs = freeze([[1]]); xs[0][0] = 2", errorMessage("cannot modify frozen ARRAY")},
+		{`let h = freeze({"a": 1}); h["b"] = 2`, errorMessage("cannot modify frozen HASH")},
+		{`let h = freeze({"a": {"b": 1}}); h["a"]["b"] = 2`, errorMessage("cannot modify frozen HASH")},
+		{"struct P { x }; let p = freeze(P { x: [1] }); p.x[0]", 1},
+		{"struct P { x }; let p = freeze(P { x: 1 }); p.x = 2", errorMessage("cannot modify frozen P")},
+		{"struct P { x }; let p = freeze(P { x: [1] }); p.x[0] = 2", errorMessage("cannot modify frozen ARRAY")},
+		{"struct P { x }; let p = P { x: 1 }; freeze([p]); p.x = 2", errorMessage("cannot modify frozen P")},
+		{"struct P { x }; let ps = freeze({\"a\": P { x: 1 }}); ps[\"a\"].x = 2", errorMessage("cannot modify frozen P")},
+		{"freeze(1)", errorMessage("argument to `freeze` must be ARRAY, HASH or STRUCT, got INTEGER")},
+		{"freeze([], [])", errorMessage("wrong number of arguments. got=2, want=1")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestFunctionApplication(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -304,6 +446,29 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = 1; "x=${x + 1}"`, "x=2"},
+		{`"${1}${2}"`, "12"},
+		{`let name = "Monkey"; "hello, ${name}!"`, "hello, Monkey!"},
+		{`"list: ${[1, "two", true]}"`, "list: [1, two, true]"},
+		{`"nested: ${"inner ${1 + 1}"}"`, "nested: inner 2"},
+		{`"hash: ${ {"a": 1}["a"] }"`, "hash: 1"},
+		{`"escaped: \${x}"`, "escaped: ${x}"},
+		{`"cost: $5"`, "cost: $5"},
+		{`str(5) + str(true) + str("s") + str([1])`, "5trues[1]"},
+		{`"${missing}"`, errorMessage("identifier not found: missing")},
+		{`str(1, 2)`, errorMessage("wrong number of arguments. got=2, want=1")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -367,6 +532,255 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`split("a,b,c", ",")`, []string{"a", "b", "c"}},
+		{`split("añb", "")`, []string{"a", "ñ", "b"}},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join([], "-")`, ""},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`upper("ünïcode")`, "ÜNÏCODE"},
+		{`lower("ÀB")`, "àb"},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "donkey")`, false},
+		{`index_of("héllo", "l")`, 2},
+		{`index_of("hello", "z")`, -1},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("aaa", "a", "b", 2)`, "bba"},
+		{`starts_with("monkey", "mon")`, true},
+		{`ends_with("monkey", "mon")`, false},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`substring("héllo", 1, 3)`, "él"},
+		{`substring("héllo", 1)`, "éllo"},
+		{`substring("héllo", -3)`, "llo"},
+		{`substring("héllo", 3, 100)`, "lo"},
+		{`ord("é")`, 233},
+		{`chr(233)`, "é"},
+		{`parse_int("42")`, 42},
+		{`parse_int(" -17 ")`, -17},
+		{`parse_int("ff", 16)`, 255},
+		{`format_int(255, 2)`, "11111111"},
+		{`format_int(-42)`, "-42"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case []string:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testStringObject(t, array.Elements[i], expectedElem)
+			}
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`split("a")`, "wrong number of arguments. got=1, want=2"},
+		{`split(1, ",")`, "argument to `split` must be STRING, got INTEGER"},
+		{`join(["a", 1], ",")`, "elements passed to `join` must be STRING, got INTEGER"},
+		{`trim()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`upper(true)`, "argument to `upper` must be STRING, got BOOLEAN"},
+		{`replace("a", "a", "b", "c")`, "argument to `replace` must be INTEGER, got STRING"},
+		{`repeat("a", -1)`, "negative count passed to `repeat`: -1"},
+		{`repeat("ab", 9223372036854775807)`, "result of `repeat` would be longer than 1073741824 bytes"},
+		{`repeat("ab", 536870913)`, "result of `repeat` would be longer than 1073741824 bytes"},
+		{`ord("ab")`, "argument to `ord` must be a single character, got \"ab\""},
+		{`chr(-1)`, "invalid character code passed to `chr`: -1"},
+		{`parse_int("12abc")`, "could not parse \"12abc\" as integer"},
+		{`parse_int("1", 99)`, "invalid base passed to `parse_int`: 99"},
+		{`format_int("1")`, "argument to `format_int` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %s. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestFloats(t *testing.T) {
+	// floats have no literal syntax, so json_decode makes them
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_decode("1.5")`, 1.5},
+		{`json_decode("2.0") == 2`, true},
+		{`json_decode("2.5") != 2`, true},
+		{`json_decode("0.5") == json_decode("5e-1")`, true},
+		{`json_decode("2.0")`, inspected("2.0")},
+		{`{json_decode("-0.0"): "zero"}[json_decode("0.0")]`, "zero"},
+		{`json_decode("1.5") + json_decode("0.25")`, errorMessage("unknown operator: FLOAT + FLOAT")},
+		{`json_decode("1.5") < 2`, errorMessage("unknown operator: FLOAT < INTEGER")},
+		{`-json_decode("2.5")`, errorMessage("unknown operator: -FLOAT")},
+		{`json_decode("1.5") + "a"`, errorMessage("type mismatch: FLOAT + STRING")},
+		{`!json_decode("0.0")`, false},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_encode(1)`, "1"},
+		{`json_encode("a\"b")`, `"a\"b"`},
+		{`json_encode([1, "two", true, first([])])`, `[1,"two",true,null]`},
+		{`json_encode({"b": [1, 2], "a": {"c": "<d>"}, 3: false})`, `{"3":false,"a":{"c":"<d>"},"b":[1,2]}`},
+		{`json_encode({"a": [1, 2]}, 2)`, "{\n  \"a\": [\n    1,\n    2\n  ]\n}"},
+		{`json_encode({"a": 1}, "\t")`, "{\n\t\"a\": 1\n}"},
+		{`json_encode(json_decode("1.5"))`, "1.5"},
+		{`json_decode("42")`, 42},
+		{`json_decode("\"héllo\"")`, "héllo"},
+		{`json_decode("true")`, true},
+		{`json_decode("null")`, nil},
+		{`json_decode("[1, 2, 3]")[2]`, 3},
+		{`json_decode("{\"a\": {\"b\": [\"c\"]}}")["a"]["b"][0]`, "c"},
+		{`json_decode(json_encode({"k": [1, {"x": first([])}]}))["k"][1]["x"]`, nil},
+		{`json_encode(fn(x) { x })`, errorMessage("cannot encode FUNCTION as JSON")},
+		{`json_encode({"f": len})`, errorMessage("cannot encode BUILTIN as JSON")},
+		{`json_encode({true: 1})`, errorMessage("cannot encode BOOLEAN as JSON object key")},
+		{`json_encode({1: "int", "1": "string"})`, errorMessage("duplicate JSON object key \"1\"")},
+		{`json_encode([1], 16)`, "[\n                1\n]"},
+		{`json_encode(1, 17)`, errorMessage("indent passed to `json_encode` is over 16: 17")},
+		{`json_encode(1, 9223372036854775807)`, errorMessage("indent passed to `json_encode` is over 16: 9223372036854775807")},
+		{`json_encode(1, true)`, errorMessage("argument to `json_encode` must be INTEGER or STRING, got BOOLEAN")},
+		{`json_decode("{")`, errorMessage("invalid JSON: unexpected EOF")},
+		{`json_decode("1 2")`, errorMessage("invalid JSON: unexpected data after top-level value")},
+		{`json_decode(1)`, errorMessage("argument to `json_decode` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFormatBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`format("plain")`, "plain"},
+		{`format("%d apples", 5)`, "5 apples"},
+		{`format("[%5d|%-5d|%05d]", 42, 42, 42)`, "[   42|42   |00042]"},
+		{`format("%s and %s", "cats", "dogs")`, "cats and dogs"},
+		{`format("%v %v %v", [1, "a"], true, 3)`, "[1, a] true 3"},
+		{`format("%-6s|%6s", "ab", "cd")`, "ab    |    cd"},
+		{`format("%.2s", "héllo")`, "hé"},
+		{`format("%q", "say \"hi\"")`, `"say \"hi\""`},
+		{`format("%q", 12)`, `"12"`},
+		{`format("%.2f", json_decode("3.14159"))`, "3.14"},
+		{`format("100%%")`, "100%"},
+		{`format("%d", "five")`, errorMessage("%d in format string passed to `format` requires INTEGER, got STRING")},
+		{`format("%d %d", 1)`, errorMessage("missing argument for %d in format string passed to `format`")},
+		{`format("%d", 1, 2)`, errorMessage("too many arguments for format string passed to `format`. got=2, want=1")},
+		{`format("%z", 1)`, errorMessage("unknown verb %z in format string passed to `format`")},
+		{`format("50%")`, errorMessage("incomplete verb at end of format string passed to `format`")},
+		{`format(1)`, errorMessage("argument to `format` must be STRING, got INTEGER")},
+		{`format()`, errorMessage("wrong number of arguments. got=0, want at least 1")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBuiltinSignatures(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		signature, ok := BuiltinSignature(name)
+		if !ok || !strings.HasPrefix(signature, name+"(") {
+			t.Errorf("wrong signature for %s. got=%q", name, signature)
+		}
+	}
+
+	if _, ok := BuiltinSignature("nope"); ok {
+		t.Errorf("signature for an unknown builtin")
+	}
+}
+
+func TestBuiltinOutput(t *testing.T) {
+	input := `
+let show = fn(x) { puts(x); };
+printf("%-4s|%3d\n", "ab", 7);
+show("inner");
+puts(1, [2]);
+"dot".puts();
+`
+
+	var out bytes.Buffer
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.SetOutput(&out)
+
+	testNullObject(t, Eval(program, env))
+
+	expected := "ab  |  7\ninner\n1\n[2]\ndot\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestOutputOfCaller(t *testing.T) {
+	var defining, calling bytes.Buffer
+
+	env := object.NewEnvironment()
+	env.SetOutput(&defining)
+	show := Eval(parser.New(lexer.New(`fn(x) { if (x) { puts(x) } }`)).ParseProgram(), env)
+
+	caller := object.NewEnvironment()
+	caller.SetOutput(&calling)
+	caller.Set("show", show)
+	Eval(parser.New(lexer.New(`show("called"); let call = fn(f, x) { f(x) }; call(show, 1)`)).ParseProgram(), caller)
+
+	if defining.String() != "" || calling.String() != "called\n1\n" {
+		t.Errorf("function printed where it was defined. defining=%q, calling=%q",
+			defining.String(), calling.String())
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -419,6 +833,12 @@ func TestArrayIndexExpressions(t *testing.T) {
 			nil,
 		}, {
 			"[1, 2, 3][-1]",
+			3,
+		}, {
+			"[1, 2, 3][-3]",
+			1,
+		}, {
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -434,6 +854,79 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[5]`, nil},
+		{`"héllo"[-6]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := tt.expected.(string)
+		if ok {
+			testStringObject(t, evaluated, str)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[-3:]`, "llo"},
+		{`"héllo"[:0]`, ""},
+		{`5[1:2]`, "ERROR: slice operator not supported: INTEGER"},
+		{`[1, 2]["a":]`, "ERROR: slice bound must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Inspect() != expected {
+					t.Errorf("wrong error. expected=%q, got=%q", expected, errObj.Inspect())
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 {
@@ -520,6 +1013,497 @@ func TestHashIndexExpression(t *testing.T) {
 	}
 }
 
+func TestImportExpression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"math.monkey": `
+puts("loading math");
+let square = fn(x) { x * x };
+let helpers = import "lib/helpers";
+let twice = fn(x) { helpers["double"](x) };
+`,
+		"lib/helpers.monkey": `let double = fn(x) { x * 2 };`,
+		"a.monkey":           `let b = import "b";`,
+		"b.monkey":           `let a = import "a";`,
+		"broken.monkey":      `let 5;`,
+		"failing.monkey":     `let x = 1 + true;`,
+		"geo.monkey":         `struct Point { x, y; fn sum() { self.x + self.y } };`,
+		"counter.monkey": `
+puts("loading counter");
+let count = 0;
+let bump = fn() { count = count + 1; puts(count); count };
+`,
+		"shared.monkey": `
+let helpers = import "lib/helpers";
+let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };
+let total = count(200);
+`,
+	}
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eval := func(input string, out *bytes.Buffer) object.Object {
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+		env := object.NewEnvironment()
+		env.SetOutput(out)
+		return Eval(program, env)
+	}
+
+	var out bytes.Buffer
+	mathPath := filepath.Join(dir, "math")
+	input := `
+let m = import "` + mathPath + `";
+let again = import "` + mathPath + `.monkey";
+m["square"](3) + again["twice"](4);
+`
+	testIntegerObject(t, eval(input, &out), 17)
+	if out.String() != "loading math\n" {
+		t.Errorf("module was not evaluated exactly once. output=%q", out.String())
+	}
+
+	mod, ok := eval(`import "`+mathPath+`"`, &out).(*object.Module)
+	if !ok {
+		t.Fatalf("import did not return a Module")
+	}
+	if mod.Path != mathPath+".monkey" {
+		t.Errorf("wrong module path. got=%q", mod.Path)
+	}
+	if mod.Inspect() != fmt.Sprintf("<module %q>", mathPath+".monkey") {
+		t.Errorf("wrong module repr. got=%q", mod.Inspect())
+	}
+	if len(mod.Members.Pairs) != 3 {
+		t.Errorf("module exposes wrong number of members. got=%d", len(mod.Members.Pairs))
+	}
+	testNullObject(t, eval(`(import "`+mathPath+`")["missing"]`, &out))
+	testIntegerObject(t, eval(`let m = import "`+mathPath+`"; m.square(m.twice(2))`, &out), 16)
+	testIntegerObject(t, eval(`let geo = import "`+filepath.Join(dir, "geo")+`"; geo.Point { x: 1, y: 2 }.sum()`, &out), 3)
+
+	errorTests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			`import "` + filepath.Join(dir, "a") + `"`,
+			"import cycle detected: " + strings.Join([]string{
+				filepath.Join(dir, "a.monkey"),
+				filepath.Join(dir, "b.monkey"),
+				filepath.Join(dir, "a.monkey"),
+			}, " -> "),
+		},
+		{
+			`import "` + filepath.Join(dir, "broken") + `"`,
+			fmt.Sprintf("could not import %q: expected next token to be IDENT, got INT instead",
+				filepath.Join(dir, "broken.monkey")),
+		},
+		{
+			`import "` + filepath.Join(dir, "failing") + `"`,
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`(import "` + mathPath + `").missing`,
+			fmt.Sprintf("module %q has no member missing", mathPath+".monkey"),
+		},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := eval(tt.input, &out).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %s", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	errObj, ok := eval(`import "`+filepath.Join(dir, "missing")+`"`, &out).(*object.Error)
+	if !ok || !strings.HasPrefix(errObj.Message, "could not import") {
+		t.Errorf("missing module did not produce an import error. got=%+v", errObj)
+	}
+
+	disabled := object.NewEnvironment()
+	disabled.DisableImports()
+	program := parser.New(lexer.New(`fn() { import "` + mathPath + `" }()`)).ParseProgram()
+	if errObj, ok := Eval(program, disabled).(*object.Error); !ok || !strings.HasSuffix(errObj.Message, "imports are disabled") {
+		t.Errorf("import ran in an environment with imports disabled. got=%+v", errObj)
+	}
+
+	// sessions importing the same module at once must not see each other's
+	// imports as a cycle
+	sharedPath := filepath.Join(dir, "shared")
+	results := make(chan object.Object)
+	for i := 0; i < 8; i++ {
+		go func() {
+			results <- eval(`import "`+sharedPath+`"`, &bytes.Buffer{})
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if result := <-results; result.Type() != object.MODULEOBJ {
+			t.Errorf("concurrent import did not return a Module. got=%+v", result)
+		}
+	}
+
+	// each session has modules of its own, printing to its own output
+	counterPath := filepath.Join(dir, "counter")
+	var first, second bytes.Buffer
+	firstEnv := object.NewEnvironment()
+	firstEnv.SetOutput(&first)
+	secondEnv := object.NewEnvironment()
+	secondEnv.SetOutput(&second)
+
+	run := func(env *object.Environment, input string) object.Object {
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+	run(firstEnv, `let c = import "`+counterPath+`"; c.bump(); c.bump();`)
+	testIntegerObject(t, run(secondEnv, `let c = import "`+counterPath+`"; c.bump()`), 1)
+	testIntegerObject(t, run(firstEnv, `c.bump()`), 3)
+	if first.String() != "loading counter\n1\n2\n3\n" || second.String() != "loading counter\n1\n" {
+		t.Errorf("sessions share a module. first=%q, second=%q", first.String(), second.String())
+	}
+}
+
+func TestTryCatchFinally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw "boom"; 1 } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom" } catch (e) { e["kind"] }`, "Error"},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{`try { throw {"message": "bad input", "kind": "ValueError"} } catch (e) { e["kind"] + ": " + e["message"] }`,
+			"ValueError: bad input"},
+		{`try { throw {"kind": "InternalError"} } catch (e) { "caught " + e["kind"] }`, "caught InternalError"},
+		{`try { 1 + true } catch (e) { e["kind"] + ": " + e["message"] }`,
+			"RuntimeError: type mismatch: INTEGER + BOOLEAN"},
+		{`try { throw 42 } catch (e) { e["value"] + 1 }`, 43},
+		{`try { throw [1, 2] } catch (e) { e["value"][1] }`, 2},
+		{`try { throw {"message": "bad", "code": 7} } catch (e) { e["value"]["code"] }`, 7},
+		{`try { throw "boom" } catch (e) { e["value"] }`, "boom"},
+		{`try { 1 + true } catch (e) { e["value"] }`, nil},
+		{`try { missing } catch { "recovered" }`, "recovered"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{`try { 1 } finally { 2 }`, 1},
+		{`try { throw "x" } catch { 1 } finally { 2 }`, 1},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let f = fn() { try { throw "a" } catch (e) { return e["message"] } }; f()`, "a"},
+		{`try { try { throw "inner" } finally { 1 } } catch (e) { e["message"] }`, "inner"},
+		{`try { try { throw "a" } catch (e) { throw e["message"] + "b" } } catch (e) { e["message"] }`, "ab"},
+		{`try { throw "a" } finally { throw "b" }`, errorMessage("b")},
+		{`throw "uncaught"; 5`, errorMessage("uncaught")},
+		{`try { throw "a" } finally { 1 }`, errorMessage("a")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	input := `let inner = fn() { throw "deep" };
+let outer = fn() { inner() };
+try { outer() } catch (e) { e["stack"] }`
+
+	evaluated := testEval(input)
+	stack, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{
+		"inner (line 2, column 25)",
+		"outer (line 3, column 12)",
+	}
+	if len(stack.Elements) != len(expected) {
+		t.Fatalf("wrong stack length. want=%d, got=%d", len(expected), len(stack.Elements))
+	}
+	for i, frame := range expected {
+		testStringObject(t, stack.Elements[i], frame)
+	}
+}
+
+func TestInternalErrors(t *testing.T) {
+	defer func(depth int, uncatchable bool) {
+		MaxCallDepth = depth
+		InternalErrorsUncatchable = uncatchable
+	}(MaxCallDepth, InternalErrorsUncatchable)
+
+	MaxCallDepth = 50
+	input := `let f = fn(n) { f(n + 1) }; try { f(0) } catch (e) { e["kind"] }`
+
+	InternalErrorsUncatchable = true
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("internal error was caught")
+	}
+	if errObj.Kind != object.INTERNALERROR || errObj.Message != "maximum call depth of 50 exceeded" {
+		t.Errorf("wrong internal error. got=%+v", errObj)
+	}
+
+	InternalErrorsUncatchable = false
+	testStringObject(t, testEval(input), object.INTERNALERROR)
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match (1) { 1 => "one", _ => "other" }`, "one"},
+		{`match (2) { 1 => "one", _ => "other" }`, "other"},
+		{`match (-1) { -1 => "minus one", _ => "other" }`, "minus one"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{`match (false) { true => 1, false => 2 }`, 2},
+		{`match ("1") { 1 => "int", _ => "other" }`, "other"},
+		{`match (5) { n => n * 2 }`, 10},
+		{`match (5) { n if n > 10 => "big", n if n > 3 => "medium", _ => "small" }`, "medium"},
+		{`match ([]) { [] => 0, _ => 1 }`, 0},
+		{`match ([1, 2]) { [a] => a, [a, b] => a + b, _ => 0 }`, 3},
+		{`match ([1, 2, 3]) { [a, b] => 0, [head, ...tail] => head + len(tail) }`, 3},
+		{`match ([1]) { [1, ...rest] => len(rest) }`, 0},
+		{`match ([1, 2]) { [_, ...] => "non-empty" }`, "non-empty"},
+		{`match ([[1, 2], 3]) { [[a, b], c] => a + b + c }`, 6},
+		{`match ({"name": "Monkey", "age": 5}) { {"name": n} => n }`, "Monkey"},
+		{`match ({"name": "Monkey"}) { {"age": a} => a, {"name": "Monkey"} => "matched" }`, "matched"},
+		{`match ({"pos": [1, 2]}) { {"pos": [x, y]} => x * 10 + y }`, 12},
+		{`match (3) { 1 => 1, n => { let doubled = n * 2; doubled + 1 } }`, 7},
+		{`let f = fn(x) { match (x) { 0 => { return "zero" }, _ => "other" }; "after" }; f(0)`, "zero"},
+		{`let f = fn(x) { match (x) { 0 => { return "zero" }, _ => "other" }; "after" }; f(1)`, "after"},
+		{`let n = 1; match (2) { n => n }; n`, 1},
+		{`match (3) { 1 => 1, 2 => 2 }`, errorMessage("no match arm matched value: 3")},
+		{`match ("x") { n if n + 1 => 1 }`, errorMessage("type mismatch: STRING + INTEGER")},
+		{`match (missing) { _ => 1 }`, errorMessage("identifier not found: missing")},
+		{`match ({}) { {fn(x) { x }: v} => v }`, errorMessage("unusable as hash key: FUNCTION")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [head, ...tail] = [1, 2, 3]; head * 10 + len(tail)", 12},
+		{"let [_, second] = [1, 2]; second", 2},
+		{"let [a, b = 5] = [1]; a + b", 6},
+		{"let [a, b = 5] = [1, 2]; a + b", 3},
+		{"let [a, b = a * 2] = [4]; b", 8},
+		{"let [x, [y, z]] = [1, [2, 3]]; x + y + z", 6},
+		{`let {"name": n} = {"name": "Monkey", "age": 5}; n`, "Monkey"},
+		{`let {"age": a = 0} = {"name": "Monkey"}; a`, 0},
+		{`let {"pos": [x, y]} = {"pos": [1, 2]}; x * 10 + y`, 12},
+		{"let f = fn([a, b]) { a + b }; f([1, 2])", 3},
+		{`let f = fn({"x": x}, y) { x + y }; f({"x": 1}, 2)`, 3},
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a, b = a + 1) { b }; f(1)", 2},
+		{"let f = fn([a, b = 2] = [1]) { a + b }; f()", 3},
+		{"let [a, b] = [1, 2, 3];", errorMessage("value [1, 2, 3] does not match pattern [a, b]")},
+		{"let [a, b] = [1];", errorMessage("value [1] does not match pattern [a, b]")},
+		{`let {"name": n} = {};`, errorMessage("value {} does not match pattern {name:n}")},
+		{"let [a] = 1;", errorMessage("value 1 does not match pattern [a]")},
+		{`let {"a": a} = [1];`, errorMessage("value [1] does not match pattern {a:a}")},
+		{"let [a = missing] = [];", errorMessage("identifier not found: missing")},
+		{"let f = fn([a, b]) { a }; f(1)", errorMessage("value 1 does not match pattern [a, b]")},
+		{"let f = fn([a] = 1) { a }; f()", errorMessage("default value does not match pattern [a] = 1")},
+		{"let f = fn(a, b = 1) { a }; f()", errorMessage("wrong number of arguments. got=0, want=1 or 2")},
+		{"let f = fn(a, b) { a }; f(1, 2, 3)", errorMessage("wrong number of arguments. got=3, want=2")},
+		{"const b = 1; let [a, b] = [2, 3];", errorMessage("cannot redeclare constant b")},
+		{"let a = 1; const b = 1; try { let [a, b] = [2, 3] } catch { a }", 1},
+		{"let a = 1; try { let [a, b] = [2] } catch { a }", 1},
+		{"let a = 1; try { let [a, [b]] = [2, 3] } catch { a }", 1},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStructs(t *testing.T) {
+	point := `struct Point {
+	x, y
+	fn norm() { self.x * self.x + self.y * self.y }
+	fn scale(k) { Point { x: self.x * k, y: self.y * k } }
+}
+`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{point + "Point { x: 1, y: 2 }.x", 1},
+		{point + "let p = Point { x: 3, y: 4 }; p.norm()", 25},
+		{point + "let p = Point { x: 1, y: 2 }; p.scale(3).y", 6},
+		{point + "let p = Point { x: 1, y: 2 }; let norm = p.norm; norm()", 5},
+		{point + "Point { x: 1, y: 2 }", inspected("Point{x: 1, y: 2}")},
+		{point + "Point { y: 2 }", inspected("Point{x: null, y: 2}")},
+		{point + "Point", inspected("<struct Point>")},
+		{point + "let p = Point { x: 1, y: 2 }; p == p", true},
+		{point + "Point { x: 1, y: 2 } == Point { x: 1, y: 2 }", false},
+		{`struct Empty {}; Empty {}`, inspected("Empty{}")},
+		{`struct Counter { n  fn get() { self.n } }; let self = 1; Counter { n: 5 }.get()`, 5},
+		{point + "Point { x: 1, z: 2 }", errorMessage("unknown field z for struct Point")},
+		{point + "Point { x: 1 }.z", errorMessage("unknown field z for struct Point")},
+		{point + "Point { x: missing }", errorMessage("identifier not found: missing")},
+		{"let p = 1; p { x: 1 }", errorMessage("not a struct type: INTEGER")},
+		{"1.x", errorMessage("unknown method x for INTEGER")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLambdasAndElseIf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let double = |x| x * 2; double(4)", 8},
+		{"(|a, b| a - b)(5, 2)", 3},
+		{"let f = || 7; f()", 7},
+		{"let add = |x| |y| x + y; add(1)(2)", 3},
+		{"let f = |x| { let y = x * 10; y + 1 }; f(2)", 21},
+		{"let apply = fn(f, x) { f(x) }; apply(|x| x + 1, 1)", 2},
+		{"let f = |[a, b], c = 1| a + b + c; f([1, 2])", 4},
+		{"5 |> (|x| x * x)()", 25},
+		{"let sign = fn(n) { if (n < 0) { -1 } else if (n > 0) { 1 } else { 0 } }; sign(-5)", -1},
+		{"let sign = fn(n) { if (n < 0) { -1 } else if (n > 0) { 1 } else { 0 } }; sign(5)", 1},
+		{"let sign = fn(n) { if (n < 0) { -1 } else if (n > 0) { 1 } else { 0 } }; sign(0)", 0},
+		{"if (false) { 1 } else if (false) { 2 } else if (true) { 3 }", 3},
+		{"if (false) { 1 } else if (false) { 2 }", nil},
+		{"let f = fn() { if (false) { 1 } else if (true) { return 2 }; 3 }; f()", 2},
+		{"(|x| x)(1, 2)", errorMessage("wrong number of arguments. got=2, want=1")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = fn(a, b) { a + b }; 1 |> add(2)", 3},
+		{"let double = fn(x) { x * 2 }; 3 |> double() |> double()", 12},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(1 + 2)", 7},
+		{"[1, 2, 3] |> push(4) |> len()", 4},
+		{`"a,b" |> split(",") |> join("-")`, "a-b"},
+		{"let f = fn(x) { fn(y) { x + y } }; 1 |> f(2)()", 3},
+		{"1 |> len()", errorMessage("argument to `len` not supported, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDotCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3].push(4).len()", 4},
+		{"let arr = [1, 2]; arr.push(3).last()", 3},
+		{`"a,b,c".split(",").len()`, 3},
+		{`"a,b,c".split(",").join("-")`, inspected("a-b-c")},
+		{`"  Monkey ".trim().upper()`, inspected("MONKEY")},
+		{`"%d-%s".format(1, "a")`, inspected("1-a")},
+		{"5.str()", inspected("5")},
+		{"let len = fn(x) { 99 }; [1, 2].len()", 2},
+		{`[1, "two", 3].contains("two")`, true},
+		{`[1, 2, 3].contains(4)`, false},
+		{`"monkey".contains("key")`, true},
+		{`[1, 2, 3].index_of(3)`, 2},
+		{`"monkey".index_of("key")`, 3},
+		{`{"b": 2, "a": 1}.keys()`, inspected("[a, b]")},
+		{`{"b": 2, "a": 1}.values()`, inspected("[1, 2]")},
+		{`{"a": 1}.has("a")`, true},
+		{`{"a": 1}.has("b")`, false},
+		{`let up = "abc".upper; up()`, inspected("ABC")},
+		{"[1].len(2)", errorMessage("wrong number of arguments. got=2, want=1")},
+		{`{"a": 1}.has(fn(x) { x })`, errorMessage("unusable as hash key: FUNCTION")},
+		{"[1].nope()", errorMessage("unknown method nope for ARRAY")},
+		{"true.str().nope", errorMessage("unknown method nope for STRING")},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// errorMessage marks an expected value as the message of an object.Error
+type errorMessage string
+
+// inspected marks an expected value as the Inspect of an object of any type
+type inspected string
+
+// testExpected checks obj against the expected value of a table test: an
+// int, float64, bool or string for an object of that type holding it, nil
+// for NULL, inspected for the Inspect of any object or errorMessage for an
+// error
+func testExpected(t *testing.T, obj object.Object, expected interface{}) bool {
+	switch expected := expected.(type) {
+	case int:
+		return testIntegerObject(t, obj, int64(expected))
+	case float64:
+		return testFloatObject(t, obj, expected)
+	case bool:
+		return testBooleanObject(t, obj, expected)
+	case string:
+		return testStringObject(t, obj, expected)
+	case nil:
+		return testNullObject(t, obj)
+	case inspected:
+		if obj == nil {
+			t.Errorf("object is nil, want %q", expected)
+			return false
+		}
+		if obj.Inspect() != string(expected) {
+			t.Errorf("wrong Inspect(). expected=%q, got=%q", expected, obj.Inspect())
+			return false
+		}
+	case errorMessage:
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+			return false
+		}
+		if errObj.Message != string(expected) {
+			t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			return false
+		}
+	default:
+		t.Errorf("unsupported expected value %T (%+v)", expected, expected)
+		return false
+	}
+	return true
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -563,3 +1547,29 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	}
 	return true
 }
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
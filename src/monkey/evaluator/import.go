@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleExtension is appended to import paths that do not name a file
+// extension themselves
+const ModuleExtension = ".monkey"
+
+// evalImportExpression returns the module at the path of node, loading it
+// the first time the interpreter of env imports it
+func evalImportExpression(
+	node *ast.ImportExpression,
+	env *object.Environment,
+) object.Object {
+	if env.ImportsDisabled() {
+		return newError("could not import %q: imports are disabled", node.Path.Value)
+	}
+
+	path, err := resolveImportPath(node.Path.Value, env)
+	if err != nil {
+		return newError("could not import %q: %s", node.Path.Value, err)
+	}
+
+	modules := env.Modules()
+	if mod, ok := modules.Get(path); ok {
+		return mod
+	}
+
+	chain := env.ImportChain()
+	for i, importing := range chain {
+		if importing == path {
+			cycle := append(append([]string{}, chain[i:]...), path)
+			return newError("import cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	result := loadModule(path, env)
+	if mod, ok := result.(*object.Module); ok {
+		modules.Set(path, mod)
+	}
+	return result
+}
+
+// resolveImportPath resolves name against the directory of the module
+// doing the import, or the working directory for the main program
+func resolveImportPath(name string, env *object.Environment) (string, error) {
+	if filepath.Ext(name) == "" {
+		name += ModuleExtension
+	}
+
+	if !filepath.IsAbs(name) {
+		if file := env.File(); file != "" {
+			name = filepath.Join(filepath.Dir(file), name)
+		}
+	}
+
+	return filepath.Abs(name)
+}
+
+// evalModule evaluates the program of a module in env. A debugger of the
+// importing program observes it as a call, and is detached afterwards so
+// later uses of the cached module are not reported to it. Each interpreter
+// caches its own modules, so a debugger running a program in a fresh
+// environment sees the top-level code of every module it imports
+func evalModule(program *ast.Program, env *object.Environment, debugger object.Debugger) object.Object {
+	if debugger == nil {
+		return Eval(program, env)
+	}
+
+	env.SetDebugger(debugger)
+	debugger.Call(nil, "<module "+filepath.Base(env.File())+">")
+	defer func() {
+		debugger.Return(nil)
+		env.SetDebugger(nil)
+	}()
+	return Eval(program, env)
+}
+
+func loadModule(path string, env *object.Environment) object.Object {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newError("could not import %q: %s", path, err)
+	}
+
+	l := lexer.New(string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("could not import %q: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	moduleEnv := object.NewEnvironment()
+	moduleEnv.SetOutput(env.Output())
+	moduleEnv.SetModules(env.Modules())
+	chain := env.ImportChain()
+	moduleEnv.SetImportChain(append(chain[:len(chain):len(chain)], path))
+
+	if result := evalModule(program, moduleEnv, env.Debugger()); isError(result) {
+		return result
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	for _, name := range moduleEnv.Names() {
+		value, _ := moduleEnv.Get(name)
+		key := &object.String{Value: name}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Module{Path: path, Members: &object.Hash{Pairs: pairs}}
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"io"
+	"monkey/object"
+)
+
+// methods holds the type-specific methods reachable with dot-call syntax.
+// Like builtins they receive the receiver as their first argument, and a
+// method here takes precedence over the builtin of the same name
+var methods = map[object.ObjectType]map[string]*object.Builtin{
+	object.ARRAYOBJ: {
+		"contains": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(args, 2); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(arrayIndexOf(args[0], args[1]) >= 0)
+			},
+		},
+		"index_of": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(args, 2); err != nil {
+					return err
+				}
+				return &object.Integer{Value: int64(arrayIndexOf(args[0], args[1]))}
+			},
+		},
+	},
+	object.HASHOBJ: {
+		"keys": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(args, 1); err != nil {
+					return err
+				}
+
+				pairs := args[0].(*object.Hash).SortedPairs()
+				keys := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					keys[i] = pair.Key
+				}
+				return &object.Array{Elements: keys}
+			},
+		},
+		"values": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(args, 1); err != nil {
+					return err
+				}
+
+				pairs := args[0].(*object.Hash).SortedPairs()
+				values := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					values[i] = pair.Value
+				}
+				return &object.Array{Elements: values}
+			},
+		},
+		"has": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(args, 2); err != nil {
+					return err
+				}
+
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				_, ok = args[0].(*object.Hash).Pairs[key.HashKey()]
+				return nativeBoolToBooleanObject(ok)
+			},
+		},
+	},
+}
+
+// evalMemberExpression resolves x.member: the fields and methods of a
+// struct, the members of a module, then the method table for the type of
+// x and finally the builtins, called with x as their first argument
+func evalMemberExpression(obj object.Object, member string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Struct:
+		if value, ok := obj.Fields[member]; ok {
+			return value
+		}
+
+		if method, ok := obj.StructType.Methods[member]; ok {
+			env := object.NewEnclosedEnvironment(method.Env)
+			env.Set("self", obj)
+			return &object.Function{
+				Name:       method.Name,
+				Parameters: method.Parameters,
+				Body:       method.Body,
+				Env:        env,
+			}
+		}
+
+	case *object.Module:
+		key := &object.String{Value: member}
+		if pair, ok := obj.Members.Pairs[key.HashKey()]; ok {
+			return pair.Value
+		}
+		return newError("module %q has no member %s", obj.Path, member)
+	}
+
+	if method, ok := methods[obj.Type()][member]; ok {
+		return bindBuiltin(method, obj)
+	}
+
+	if builtin, ok := builtins[member]; ok {
+		return bindBuiltin(builtin, obj)
+	}
+
+	if instance, ok := obj.(*object.Struct); ok {
+		return newError("unknown field %s for struct %s", member, instance.StructType.Name)
+	}
+
+	return newError("unknown method %s for %s", member, obj.Type())
+}
+
+// bindBuiltin returns a builtin that calls builtin with receiver prepended
+// to its arguments
+func bindBuiltin(builtin *object.Builtin, receiver object.Object) *object.Builtin {
+	if builtin.Print != nil {
+		return &object.Builtin{
+			Print: func(out io.Writer, args ...object.Object) object.Object {
+				return builtin.Print(out, append([]object.Object{receiver}, args...)...)
+			},
+		}
+	}
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return builtin.Fn(append([]object.Object{receiver}, args...)...)
+		},
+	}
+}
+
+func arrayIndexOf(array, value object.Object) int {
+	for i, el := range array.(*object.Array).Elements {
+		if objectsEqual(el, value) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,216 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+func evalMatchExpression(
+	node *ast.MatchExpression,
+	env *object.Environment,
+) object.Object {
+	subject := Eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range node.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+
+		matched, err := matchPattern(arm.Pattern, subject, armEnv)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newError("no match arm matched value: %s", subject.Inspect())
+}
+
+// destructure binds the identifiers in pattern to the matching parts of
+// value, as constants if constant is set, failing when value does not have
+// the shape pattern describes. The pattern is matched in a scratch
+// environment first, so env is left untouched unless every identifier can
+// be bound
+func destructure(
+	pattern ast.Expression,
+	value object.Object,
+	env *object.Environment,
+	constant bool,
+) *object.Error {
+	scratch := object.NewEnclosedEnvironment(env)
+
+	matched, err := matchPattern(pattern, value, scratch)
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		if value == nil {
+			return newError("default value does not match pattern %s", pattern)
+		}
+		return newError("value %s does not match pattern %s", value.Inspect(), pattern)
+	}
+
+	names := scratch.Names()
+	for _, name := range names {
+		if env.IsConst(name) {
+			return newError("cannot redeclare constant %s", name)
+		}
+	}
+	for _, name := range names {
+		bound, _ := scratch.Get(name)
+		env.Define(name, bound, constant)
+	}
+
+	return nil
+}
+
+// matchPattern reports whether value has the shape described by pattern,
+// binding the identifiers in the pattern into env as it goes. A nil value
+// stands for a missing array element or hash entry, which only a default
+// pattern matches
+func matchPattern(
+	pattern ast.Expression,
+	value object.Object,
+	env *object.Environment,
+) (bool, *object.Error) {
+	if def, ok := pattern.(*ast.DefaultPattern); ok {
+		if value == nil {
+			value = Eval(def.Default, env)
+			if errObj, ok := value.(*object.Error); ok {
+				return false, errObj
+			}
+		}
+		pattern = def.Pattern
+	}
+
+	if value == nil {
+		return false, nil
+	}
+
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" && !env.Define(pattern.Value, value, false) {
+			return false, newError("cannot redeclare constant %s", pattern.Value)
+		}
+		return true, nil
+
+	case *ast.ArrayPattern:
+		return matchArrayPattern(pattern, value, env)
+
+	case *ast.HashPattern:
+		return matchHashPattern(pattern, value, env)
+
+	default:
+		expected := Eval(pattern, env)
+		if errObj, ok := expected.(*object.Error); ok {
+			return false, errObj
+		}
+		return objectsEqual(expected, value), nil
+	}
+}
+
+func matchArrayPattern(
+	pattern *ast.ArrayPattern,
+	value object.Object,
+	env *object.Environment,
+) (bool, *object.Error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return false, nil
+	}
+
+	length := len(array.Elements)
+	if pattern.Rest == nil && length > len(pattern.Elements) {
+		return false, nil
+	}
+
+	for i, element := range pattern.Elements {
+		var el object.Object
+		if i < length {
+			el = array.Elements[i]
+		}
+
+		matched, err := matchPattern(element, el, env)
+		if err != nil || !matched {
+			return matched, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := []object.Object{}
+		if length > len(pattern.Elements) {
+			rest = append(rest, array.Elements[len(pattern.Elements):]...)
+		}
+		return matchPattern(pattern.Rest, &object.Array{Elements: rest}, env)
+	}
+
+	return true, nil
+}
+
+func matchHashPattern(
+	pattern *ast.HashPattern,
+	value object.Object,
+	env *object.Environment,
+) (bool, *object.Error) {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return false, nil
+	}
+
+	for i, keyNode := range pattern.Keys {
+		key := Eval(keyNode, env)
+		if errObj, ok := key.(*object.Error); ok {
+			return false, errObj
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return false, newError("unusable as hash key: %s", key.Type())
+		}
+
+		var entry object.Object
+		if pair, ok := hash.Pairs[hashKey.HashKey()]; ok {
+			entry = pair.Value
+		}
+
+		matched, err := matchPattern(pattern.Values[i], entry, env)
+		if err != nil || !matched {
+			return matched, err
+		}
+	}
+
+	return true, nil
+}
+
+// objectsEqual compares literal values by type and content
+func objectsEqual(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.Float:
+		b, ok := b.(*object.Float)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	default:
+		return a == b
+	}
+}
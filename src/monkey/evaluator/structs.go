@@ -0,0 +1,70 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+func evalStructStatement(
+	node *ast.StructStatement,
+	env *object.Environment,
+) *object.StructType {
+	structType := &object.StructType{
+		Name:    node.Name.Value,
+		Fields:  []string{},
+		Methods: make(map[string]*object.Function),
+	}
+
+	for _, field := range node.Fields {
+		structType.Fields = append(structType.Fields, field.Value)
+	}
+
+	for _, method := range node.Methods {
+		structType.Methods[method.Name.Value] = &object.Function{
+			Name:       method.Name.Value,
+			Parameters: method.Function.Parameters,
+			Body:       method.Function.Body,
+			Env:        env,
+		}
+	}
+
+	return structType
+}
+
+func evalStructLiteral(
+	node *ast.StructLiteral,
+	env *object.Environment,
+) object.Object {
+	typ := Eval(node.Type, env)
+	if isError(typ) {
+		return typ
+	}
+
+	structType, ok := typ.(*object.StructType)
+	if !ok {
+		return newError("not a struct type: %s", typ.Type())
+	}
+
+	instance := &object.Struct{
+		StructType: structType,
+		Fields:     make(map[string]object.Object),
+	}
+	for _, name := range structType.Fields {
+		instance.Fields[name] = NULL
+	}
+
+	for i, field := range node.Fields {
+		if !structType.HasField(field.Value) {
+			return newError("unknown field %s for struct %s", field.Value, structType.Name)
+		}
+
+		value := Eval(node.Values[i], env)
+		if isError(value) {
+			return value
+		}
+
+		instance.Fields[field.Value] = value
+	}
+
+	return instance
+}
@@ -0,0 +1,740 @@
+// Package format lays out Monkey source in its canonical style: one
+// statement per line, blocks indented by four spaces, and lists that do
+// not fit the width broken one element per line. Comments are kept.
+package format
+
+import (
+	"errors"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/pretty"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// WIDTH is the line width lists are broken beyond
+const WIDTH = 80
+
+// INDENT is the indentation of each nesting level
+const INDENT = "    "
+
+// primary is the precedence of expressions that never need parentheses
+const primary = parser.INDEX + 1
+
+// ParseError lists the errors of source that does not parse
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// errUnstable is returned when formatting fails its own guarantees, which
+// is a bug in the formatter
+var errUnstable = errors.New("formatting changed the meaning of the source")
+
+// Source returns src in canonical form. The result parses to the same
+// program as src, keeps its comments, and formats to itself
+func Source(src string) (string, error) {
+	program, comments, err := parse(src)
+	if err != nil {
+		return "", err
+	}
+	out := printProgram(src, program, comments)
+
+	again, againComments, err := parse(out)
+	if err != nil || again.String() != program.String() || len(againComments) != len(comments) {
+		return "", errUnstable
+	}
+	if printProgram(out, again, againComments) != out {
+		return "", errUnstable
+	}
+
+	return out, nil
+}
+
+func parse(src string) (*ast.Program, []token.Token, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, nil, &ParseError{Errors: p.Errors()}
+	}
+
+	return program, l.Comments(), nil
+}
+
+func printProgram(src string, program *ast.Program, comments []token.Token) string {
+	p := &printer{src: strings.Split(src, "\n"), comments: comments}
+
+	end := token.Token{Type: token.EOF, Line: len(p.src) + 1}
+	p.statements(program.Statements, end, false)
+	if len(p.out) > 0 {
+		p.write("\n")
+	}
+
+	return string(p.out)
+}
+
+// printer writes formatted source. Layouts are tried on copies of the
+// printer, which are kept when the layout fits
+type printer struct {
+	out   []byte
+	col   int // column of the next character, from 0
+	lines int // newlines written
+	depth int
+
+	flat     bool // everything must fit on the current line
+	failed   bool // the layout being tried does not fit
+	overflow bool // a line passed the width
+
+	src      []string // lines of the source, for blank lines and comments
+	comments []token.Token
+	next     int // index of the first comment not yet written
+}
+
+func (p *printer) write(s string) {
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			break
+		}
+		p.out = append(p.out, s[:i+1]...)
+		p.col += utf8.RuneCountInString(s[:i])
+		p.checkWidth()
+		p.col = 0
+		p.lines++
+		s = s[i+1:]
+	}
+
+	p.out = append(p.out, s...)
+	p.col += utf8.RuneCountInString(s)
+	p.checkWidth()
+}
+
+func (p *printer) checkWidth() {
+	if p.col > WIDTH {
+		p.overflow = true
+	}
+}
+
+func (p *printer) newline() {
+	if p.flat {
+		p.failed = true
+		return
+	}
+	p.write("\n" + strings.Repeat(INDENT, p.depth))
+}
+
+// attempt runs layout on a copy of the printer and keeps the result when
+// it fits, reporting whether it did
+func (p *printer) attempt(layout func(q *printer)) bool {
+	q := *p
+	q.failed, q.overflow = false, false
+
+	layout(&q)
+	if q.failed || q.overflow {
+		return false
+	}
+
+	q.flat, q.failed, q.overflow = p.flat, p.failed, p.overflow
+	*p = q
+	return true
+}
+
+// oneLine lays out with layout on a single line if it fits, and over
+// several lines otherwise
+func (p *printer) oneLine(layout func(q *printer)) {
+	if p.flat || !p.attempt(func(q *printer) { q.flat = true; layout(q) }) {
+		layout(p)
+	}
+}
+
+// list writes n items between open and close, on one line when they fit
+// with only the last item spreading over lines, and one per line otherwise
+func (p *printer) list(open, close string, n int, item func(q *printer, i int)) {
+	if n == 0 {
+		p.write(open + close)
+		return
+	}
+
+	fits := p.attempt(func(q *printer) {
+		lines := q.lines
+		q.write(open)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				q.write(", ")
+			}
+			if q.lines != lines {
+				q.failed = true
+				return
+			}
+			item(q, i)
+		}
+		q.write(close)
+	})
+	if fits {
+		return
+	}
+	if p.flat {
+		p.failed = true
+		return
+	}
+
+	p.write(open)
+	p.depth++
+	for i := 0; i < n; i++ {
+		p.newline()
+		item(p, i)
+		if i < n-1 {
+			p.write(",")
+		}
+	}
+	p.depth--
+	p.newline()
+	p.write(close)
+}
+
+// statements writes stmts one per line, followed by the comments before
+// end. Inside a block the first statement starts on a new line as well
+func (p *printer) statements(stmts []ast.Statement, end token.Token, block bool) {
+	items := 0
+	for i, stmt := range stmts {
+		var next ast.Statement
+		boundary := end
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+			boundary = ast.StatementToken(next)
+		}
+
+		pos := ast.StatementToken(stmt)
+		p.leadingComments(pos, &items, block)
+		p.beginItem(pos.Line, &items, block)
+
+		p.statement(stmt)
+		if p.needsSemicolon(stmt, next, block) {
+			p.write(";")
+		}
+		p.trailingComment(boundary)
+	}
+
+	p.leadingComments(end, &items, block)
+}
+
+// beginItem starts the line of the next statement or comment, keeping a
+// blank line the source had above it
+func (p *printer) beginItem(line int, items *int, block bool) {
+	if *items > 0 {
+		if line >= 2 && strings.TrimSpace(p.src[line-2]) == "" {
+			p.write("\n")
+		}
+		p.newline()
+	} else if block {
+		p.newline()
+	}
+	*items++
+}
+
+// leadingComments writes the comments before pos on lines of their own
+func (p *printer) leadingComments(pos token.Token, items *int, block bool) {
+	for p.next < len(p.comments) && before(p.comments[p.next], pos) {
+		comment := p.comments[p.next]
+		p.beginItem(comment.Line, items, block)
+		p.write(comment.Literal)
+		p.next++
+	}
+}
+
+// trailingComment writes the next comment at the end of the line when the
+// source had it after code, and it comes before boundary
+func (p *printer) trailingComment(boundary token.Token) {
+	if p.next >= len(p.comments) {
+		return
+	}
+
+	comment := p.comments[p.next]
+	if before(comment, boundary) && p.afterCode(comment) {
+		p.write(" " + comment.Literal)
+		p.next++
+	}
+}
+
+func (p *printer) afterCode(comment token.Token) bool {
+	line := p.src[comment.Line-1]
+	return strings.TrimSpace(line[:comment.Column-1]) != ""
+}
+
+// commentsWithin reports whether there are unwritten comments inside b
+func (p *printer) commentsWithin(b *ast.BlockStatement) bool {
+	return p.next < len(p.comments) && before(p.comments[p.next], b.Rbrace)
+}
+
+func before(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// needsSemicolon reports whether stmt is ended with a semicolon. Only
+// expression statements can leave it out: the last one of a block, and
+// ones ending in a block unless next would otherwise continue them
+func (p *printer) needsSemicolon(stmt, next ast.Statement, block bool) bool {
+	switch stmt := stmt.(type) {
+	case *ast.FunctionStatement, *ast.StructStatement:
+		return false
+	case *ast.ExpressionStatement:
+		if block && next == nil {
+			return false
+		}
+
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.MatchExpression, *ast.TryExpression:
+			return next != nil && p.continues(next)
+		}
+	}
+	return true
+}
+
+// continues reports whether stmt starts with a token that would extend the
+// expression before it
+func (p *printer) continues(stmt ast.Statement) bool {
+	q := *p
+	q.out = nil
+	q.statement(stmt)
+
+	return len(q.out) > 0 && strings.IndexByte("([{-", q.out[0]) >= 0
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write(stmt.Token.Literal + " ")
+		if stmt.Pattern != nil {
+			p.pattern(stmt.Pattern)
+		} else {
+			p.write(stmt.Name.Value)
+		}
+		p.write(" = ")
+		p.expression(stmt.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(stmt.Value, parser.LOWEST)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+	case *ast.FunctionStatement:
+		p.function("fn "+stmt.Name.Value, stmt.Function)
+	case *ast.StructStatement:
+		p.structStatement(stmt)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+// block writes b on one line when it holds a single expression that fits,
+// and indented over several lines otherwise
+func (p *printer) block(b *ast.BlockStatement) {
+	if p.commentsWithin(b) {
+		p.indentedBlock(b)
+		return
+	}
+
+	if len(b.Statements) == 0 {
+		p.write("{}")
+		return
+	}
+
+	if stmt, ok := b.Statements[0].(*ast.ExpressionStatement); ok && len(b.Statements) == 1 {
+		fits := p.attempt(func(q *printer) {
+			q.flat = true
+			q.write("{ ")
+			q.expression(stmt.Expression, parser.LOWEST)
+			q.write(" }")
+		})
+		if fits {
+			return
+		}
+	}
+
+	p.indentedBlock(b)
+}
+
+func (p *printer) indentedBlock(b *ast.BlockStatement) {
+	if p.flat {
+		p.failed = true
+		return
+	}
+
+	p.write("{")
+	p.depth++
+	p.statements(b.Statements, b.Rbrace, true)
+	p.depth--
+	p.newline()
+	p.write("}")
+}
+
+// chainBlock writes a block of an if or try chain, whose blocks are all on
+// one line or all indented
+func (p *printer) chainBlock(b *ast.BlockStatement) {
+	if p.flat {
+		p.block(b)
+	} else {
+		p.indentedBlock(b)
+	}
+}
+
+func (p *printer) function(prefix string, fn *ast.FunctionLiteral) {
+	p.write(prefix)
+	p.list("(", ")", len(fn.Parameters), func(q *printer, i int) {
+		q.pattern(fn.Parameters[i])
+	})
+	p.write(" ")
+	p.block(fn.Body)
+}
+
+func (p *printer) lambda(fn *ast.FunctionLiteral) {
+	p.list("|", "|", len(fn.Parameters), func(q *printer, i int) {
+		q.pattern(fn.Parameters[i])
+	})
+	p.write(" ")
+
+	if fn.Body.Token.Type == token.LBRACE {
+		p.block(fn.Body)
+		return
+	}
+	p.body(fn.Body.Statements[0].(*ast.ExpressionStatement).Expression)
+}
+
+// body writes the expression body of a lambda or match arm, in parentheses
+// when it would otherwise start with a brace and be read as a block
+func (p *printer) body(e ast.Expression) {
+	q := *p
+	start := len(q.out)
+	q.expression(e, parser.LOWEST)
+	if q.out[start] != '{' {
+		*p = q
+		return
+	}
+
+	p.write("(")
+	p.expression(e, parser.LOWEST)
+	p.write(")")
+}
+
+func (p *printer) structStatement(s *ast.StructStatement) {
+	p.write("struct " + s.Name.Value + " ")
+
+	fields := []string{}
+	for _, field := range s.Fields {
+		fields = append(fields, field.Value)
+	}
+
+	if len(s.Methods) == 0 {
+		if len(fields) == 0 {
+			p.write("{}")
+			return
+		}
+		if p.attempt(func(q *printer) { q.write("{ " + strings.Join(fields, ", ") + " }") }) {
+			return
+		}
+	}
+	if p.flat {
+		p.failed = true
+		return
+	}
+
+	p.write("{")
+	p.depth++
+	if len(fields) > 0 {
+		p.newline()
+		p.write(strings.Join(fields, ", "))
+	}
+	for i, method := range s.Methods {
+		if len(fields) > 0 || i > 0 {
+			p.write("\n")
+		}
+		for p.next < len(p.comments) && before(p.comments[p.next], method.Function.Token) {
+			p.newline()
+			p.write(p.comments[p.next].Literal)
+			p.next++
+		}
+		p.newline()
+		p.function("fn "+method.Name.Value, method.Function)
+	}
+	p.depth--
+	p.newline()
+	p.write("}")
+}
+
+// precedence returns the precedence of e, which decides where it needs
+// parentheses
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.CallExpression:
+		if e.Pipe {
+			return parser.PIPE
+		}
+	case *ast.FunctionLiteral:
+		// the body of `|x| x + 1` takes in everything after it
+		if e.Token.Type == token.BAR && e.Body.Token.Type != token.LBRACE {
+			return parser.LOWEST
+		}
+	}
+	return primary
+}
+
+// expression writes e, in parentheses when it binds less tightly than min
+func (p *printer) expression(e ast.Expression, min int) {
+	if precedence(e) < min {
+		p.write("(")
+		p.expr(e)
+		p.write(")")
+		return
+	}
+	p.expr(e)
+}
+
+func (p *printer) expr(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+	case *ast.Boolean:
+		p.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(pretty.Quote(e.Value))
+	case *ast.InterpolatedString:
+		p.interpolatedString(e)
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expression(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := precedence(e)
+		p.expression(e.Left, prec)
+		p.write(" " + e.Operator + " ")
+		p.expression(e.Right, prec+1)
+	case *ast.AssignExpression:
+		p.expression(e.Target, parser.CALL)
+		p.write(" = ")
+		p.expression(e.Value, parser.LOWEST)
+	case *ast.IfExpression:
+		p.oneLine(func(q *printer) { q.ifExpression(e) })
+	case *ast.TryExpression:
+		p.oneLine(func(q *printer) { q.tryExpression(e) })
+	case *ast.MatchExpression:
+		p.matchExpression(e)
+	case *ast.FunctionLiteral:
+		if e.Token.Type == token.BAR {
+			p.lambda(e)
+		} else {
+			p.function("fn", e)
+		}
+	case *ast.CallExpression:
+		p.call(e)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", len(e.Elements), func(q *printer, i int) {
+			q.expression(e.Elements[i], parser.LOWEST)
+		})
+	case *ast.HashLiteral:
+		p.list("{", "}", len(e.Keys), func(q *printer, i int) {
+			q.expression(e.Keys[i], parser.LOWEST)
+			q.write(": ")
+			q.expression(e.Pairs[e.Keys[i]], parser.LOWEST)
+		})
+	case *ast.StructLiteral:
+		p.expression(e.Type, parser.CALL)
+		p.list("{", "}", len(e.Fields), func(q *printer, i int) {
+			q.write(e.Fields[i].Value + ": ")
+			q.expression(e.Values[i], parser.LOWEST)
+		})
+	case *ast.IndexExpression:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		if e.Start != nil {
+			p.expression(e.Start, parser.LOWEST)
+		}
+		p.write(":")
+		if e.End != nil {
+			p.expression(e.End, parser.LOWEST)
+		}
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expression(e.Object, parser.CALL)
+		p.write("." + e.Member.Value)
+	case *ast.ImportExpression:
+		p.write("import " + pretty.Quote(e.Path.Value))
+	default:
+		p.pattern(e)
+	}
+}
+
+func (p *printer) interpolatedString(e *ast.InterpolatedString) {
+	p.write(`"`)
+	for i, part := range e.Parts {
+		// string parts and embedded expressions alternate
+		if str, ok := part.(*ast.StringLiteral); ok && i%2 == 0 {
+			quoted := pretty.Quote(str.Value)
+			p.write(quoted[1 : len(quoted)-1])
+			continue
+		}
+		p.write("${")
+		p.expression(part, parser.LOWEST)
+		p.write("}")
+	}
+	p.write(`"`)
+}
+
+func (p *printer) call(e *ast.CallExpression) {
+	args := e.Arguments
+	if e.Pipe {
+		p.expression(args[0], parser.PIPE)
+		p.write(" |> ")
+		args = args[1:]
+	}
+
+	if fn, ok := e.Function.(*ast.FunctionLiteral); ok && fn.Token.Type == token.FUNCTION {
+		p.write("(")
+		p.expr(fn)
+		p.write(")")
+	} else {
+		p.expression(e.Function, parser.CALL)
+	}
+
+	p.list("(", ")", len(args), func(q *printer, i int) {
+		q.expression(args[i], parser.LOWEST)
+	})
+}
+
+func (p *printer) ifExpression(e *ast.IfExpression) {
+	p.write("if (")
+	p.expression(e.Condition, parser.LOWEST)
+	p.write(") ")
+	p.chainBlock(e.Consequence)
+
+	if e.Alternative == nil {
+		return
+	}
+	p.write(" else ")
+
+	if e.Alternative.Token.Type == token.IF {
+		stmt := e.Alternative.Statements[0].(*ast.ExpressionStatement)
+		p.ifExpression(stmt.Expression.(*ast.IfExpression))
+		return
+	}
+	p.chainBlock(e.Alternative)
+}
+
+func (p *printer) tryExpression(e *ast.TryExpression) {
+	p.write("try ")
+	p.chainBlock(e.Block)
+
+	if e.Catch != nil {
+		p.write(" catch ")
+		if e.Param != nil {
+			p.write("(" + e.Param.Value + ") ")
+		}
+		p.chainBlock(e.Catch)
+	}
+
+	if e.Finally != nil {
+		p.write(" finally ")
+		p.chainBlock(e.Finally)
+	}
+}
+
+func (p *printer) matchExpression(e *ast.MatchExpression) {
+	p.write("match (")
+	p.expression(e.Subject, parser.LOWEST)
+	p.write(") {")
+
+	if len(e.Arms) == 0 {
+		p.write("}")
+		return
+	}
+	if p.flat {
+		p.failed = true
+		return
+	}
+
+	p.depth++
+	items := 0
+	for i, arm := range e.Arms {
+		p.leadingComments(arm.Token, &items, true)
+		p.beginItem(arm.Token.Line, &items, true)
+
+		p.pattern(arm.Pattern)
+		if arm.Guard != nil {
+			p.write(" if ")
+			p.expression(arm.Guard, parser.LOWEST)
+		}
+		p.write(" => ")
+
+		if block, ok := arm.Body.(*ast.BlockStatement); ok {
+			p.block(block)
+		} else {
+			p.body(arm.Body.(ast.Expression))
+			if i < len(e.Arms)-1 {
+				p.write(",")
+			}
+		}
+
+		if i < len(e.Arms)-1 {
+			p.trailingComment(e.Arms[i+1].Token)
+		}
+	}
+	p.depth--
+	p.newline()
+	p.write("}")
+}
+
+// pattern writes a destructuring pattern, or an expression that is used
+// as one
+func (p *printer) pattern(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.ArrayPattern:
+		n := len(e.Elements)
+		if e.Rest != nil {
+			n++
+		}
+		p.list("[", "]", n, func(q *printer, i int) {
+			if i < len(e.Elements) {
+				q.pattern(e.Elements[i])
+			} else if e.Rest.Value == "_" {
+				q.write("...")
+			} else {
+				q.write("..." + e.Rest.Value)
+			}
+		})
+	case *ast.HashPattern:
+		p.list("{", "}", len(e.Keys), func(q *printer, i int) {
+			q.expression(e.Keys[i], parser.LOWEST)
+			q.write(": ")
+			q.pattern(e.Values[i])
+		})
+	case *ast.DefaultPattern:
+		p.pattern(e.Pattern)
+		p.write(" = ")
+		p.expression(e.Default, parser.LOWEST)
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral,
+		*ast.Boolean, *ast.PrefixExpression:
+		p.expr(e)
+	default:
+		p.write(e.String())
+	}
+}
@@ -0,0 +1,129 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"", ""},
+		{"a+b*c;(a+b)*c;a-(b-c);(a-b)-c", "a + b * c;\n(a + b) * c;\na - (b - c);\na - b - c;\n"},
+		{"-(-a);!(a==b);(-a)[0];-a[0]", "--a;\n!(a == b);\n(-a)[0];\n-a[0];\n"},
+		{"x=y=1;(x=1)+2", "x = y = 1;\n(x = 1) + 2;\n"},
+		{"xs|>map(|x|x*2)|>sum();(|x|x)(1);(a|>f())(1)",
+			"xs |> map(|x| x * 2) |> sum();\n(|x| x)(1);\n(a |> f())(1);\n"},
+		{"let f=|x|{x};let g=|x|({\"a\":x})", "let f = |x| { x };\nlet g = |x| ({\"a\": x});\n"},
+		{`let s="a\"b\n${x+1}\${y}"`, `let s = "a\"b\n${x + 1}\${y}";` + "\n"},
+		{"let add=fn(x,y){x+y};fn id(x){x}", "let add = fn(x, y) { x + y };\nfn id(x) { x }\n"},
+		{"let f=fn(){let a=1;a}", "let f = fn() {\n    let a = 1;\n    a\n};\n"},
+		{"if(a){1}else if(b){2}else{3}", "if (a) { 1 } else if (b) { 2 } else { 3 }\n"},
+		{"if(a){1}else{let b=2;b}", "if (a) {\n    1\n} else {\n    let b = 2;\n    b\n}\n"},
+		{"if(a){b}\n[1]", "if (a) { b }[1];\n"},
+		{"if(a){b};[1];if(c){d};e", "if (a) { b };\n[1];\nif (c) { d }\ne;\n"},
+		{"try{f()}catch(e){0}finally{g()}", "try { f() } catch (e) { 0 } finally { g() }\n"},
+		{"match(x){1=>\"one\",[a,...rest] if a>0=>{puts(a);a}_=>({\"k\":1})}",
+			"match (x) {\n    1 => \"one\",\n    [a, ...rest] if a > 0 => {\n        puts(a);\n        a\n    }\n    _ => ({\"k\": 1})\n}\n"},
+		{"let [a,b=2,...]=xs;let {\"k\":v}=h;fn f([x,y],z=1){x}",
+			"let [a, b = 2, ...] = xs;\nlet {\"k\": v} = h;\nfn f([x, y], z = 1) { x }\n"},
+		{"struct P{x,y}", "struct P { x, y }\n"},
+		{"struct P{x;fn m(){self.x}fn n(){1}}",
+			"struct P {\n    x\n\n    fn m() { self.x }\n\n    fn n() { 1 }\n}\n"},
+		{"P{x:1,y:f(2)}.x;import \"lib\";s[1:];s[:2]", "P{x: 1, y: f(2)}.x;\nimport \"lib\";\ns[1:];\ns[:2];\n"},
+		{`{"b":1,"a":2}`, `{"b": 1, "a": 2};` + "\n"},
+		{"let result = reduce(filter(map(numbers, fn(x) { x * x }), fn(x) { x > 2 }), 0, fn(a, x) { a + x });",
+			"let result = reduce(\n    filter(map(numbers, fn(x) { x * x }), fn(x) { x > 2 }),\n    0,\n    fn(a, x) { a + x }\n);\n"},
+		{"each(xs,fn(x){puts(x);x})", "each(xs, fn(x) {\n    puts(x);\n    x\n});\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("wrong format of %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// header
+
+let x = 1;   // one
+// about y
+let y = fn() { // opens
+  2 // two
+  // end of body
+};
+fn empty() {
+  // nothing yet
+}
+// trailer`
+
+	expected := `// header
+
+let x = 1; // one
+// about y
+let y = fn() {
+    // opens
+    2 // two
+    // end of body
+};
+fn empty() {
+    // nothing yet
+}
+// trailer
+`
+
+	got, err := Source(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != expected {
+		t.Errorf("wrong format.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestSourceIdempotent(t *testing.T) {
+	inputs := []string{
+		"let h = {\n\"a\": 1, // first\n\"b\": 2 // second\n};",
+		"f(a, // c\nfn() { b })",
+		"match (x) { 1 => 2 // one\n} // after",
+		strings.Repeat("let x = [1, 2, 3];\n", 3) + "let y = " + strings.Repeat("[100000, ", 10) + "1" + strings.Repeat("]", 10),
+		"let x = \"" + strings.Repeat("long ", 20) + "\";",
+	}
+
+	for _, input := range inputs {
+		once, err := Source(input)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", input, err)
+			continue
+		}
+		twice, err := Source(once)
+		if err != nil || twice != once {
+			t.Errorf("%q: formatting not idempotent.\nonce=%q\ntwice=%q (%v)", input, once, twice, err)
+		}
+		if strings.Count(once, "//") != strings.Count(input, "//") {
+			t.Errorf("%q: comments lost. got=%q", input, once)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	_, err := Source("let = 1; let x 2")
+
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a ParseError, got %T (%v)", err, err)
+	}
+	if len(parseErr.Errors) == 0 {
+		t.Errorf("ParseError carries no errors")
+	}
+}
@@ -1,6 +1,9 @@
 package lexer
 
-import "monkey/token"
+import (
+	"monkey/token"
+	"strings"
+)
 
 // Lexer structure
 type Lexer struct {
@@ -8,11 +11,19 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+
+	// templates holds, for each string interpolation being lexed, the
+	// number of braces opened inside it that are still unclosed
+	templates []int
+
+	comments []token.Token
 }
 
 // New returns new created Lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -22,6 +33,12 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	for l.ch == '/' && l.peekChar() == '/' {
+		l.readComment()
+		l.skipWhitespace()
+	}
+
+	line, column := l.line, l.column
 
 	switch l.ch {
 	case '=':
@@ -30,6 +47,11 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -54,6 +76,15 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '|':
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.PIPE, Literal: literal}
+		} else {
+			tok = newToken(token.BAR, l.ch)
+		}
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '/':
@@ -63,18 +94,35 @@ func (l *Lexer) NextToken() token.Token {
 	case '>':
 		tok = newToken(token.GT, l.ch)
 	case '{':
+		if len(l.templates) > 0 {
+			l.templates[len(l.templates)-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		if depth := len(l.templates) - 1; depth >= 0 && l.templates[depth] == 0 {
+			l.templates = l.templates[:depth]
+			tok = l.readStringToken(token.TEMPLATE_TAIL, token.TEMPLATE_MIDDLE)
+			break
+		} else if depth >= 0 {
+			l.templates[depth]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readStringToken(token.STRING, token.TEMPLATE_HEAD)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(1) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -82,16 +130,19 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+	tok.Line, tok.Column = line, column
 	l.readChar()
 	return tok
 }
@@ -101,6 +152,12 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -128,6 +185,24 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// readComment records the `//` comment running to the end of the line
+func (l *Lexer) readComment() {
+	tok := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	tok.Literal = strings.TrimRight(l.input[position:l.position], " \t\r")
+
+	l.comments = append(l.comments, tok)
+}
+
+// Comments returns the comments skipped so far, in source order
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
 func (l *Lexer) readNumber() string {
 	position := l.position
 	for isDigit(l.ch) {
@@ -141,21 +216,75 @@ func isDigit(ch byte) bool {
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+	return l.peekCharAt(0)
+}
+
+func (l *Lexer) peekCharAt(offset int) byte {
+	if l.readPosition+offset >= len(l.input) {
 		return 0
 	}
 
-	return l.input[l.readPosition]
+	return l.input[l.readPosition+offset]
+}
+
+// readStringToken reads the string starting at the current quote or
+// closing interpolation brace. It is a closed token when the string ends,
+// an interpolated one when `${` follows, and ILLEGAL with the raw source as
+// its literal when the input ends first
+func (l *Lexer) readStringToken(closed, interpolated token.TokenType) token.Token {
+	start := l.position
+
+	literal, end := l.readString()
+	switch end {
+	case '"':
+		return token.Token{Type: closed, Literal: literal}
+	case '{':
+		return token.Token{Type: interpolated, Literal: literal}
+	default:
+		return token.Token{Type: token.ILLEGAL, Literal: l.input[start:l.position]}
+	}
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readString reads string characters up to the closing quote, the start of
+// an interpolation or the end of input, returning the character it stopped on.
+// \n, \t, \r, \", \\ and \$ stand for the character they escape, so a string
+// can hold a quote, as JSON text does, or a literal ${
+func (l *Lexer) readString() (string, byte) {
+	var out strings.Builder
 	for {
 		l.readChar()
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
+
+		if l.ch == '$' && l.peekChar() == '{' {
+			l.readChar()
+			l.templates = append(l.templates, 0)
+			return out.String(), l.ch
+		}
+
+		if l.ch == '\\' {
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case '"', '\\', '$':
+				out.WriteByte(l.ch)
+			case 0:
+				return out.String(), l.ch
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(l.ch)
+			}
+			continue
+		}
+
+		out.WriteByte(l.ch)
 	}
 
-	return l.input[position:l.position]
+	return out.String(), l.ch
 }
@@ -30,6 +30,12 @@ if (5 < 10) {
 "foo bar"
 [1, 2];
 {"foo": "bar"}
+"say \"hi\"\n"
+"a\\b\q"
+match (x) { [a, ...rest] => a }
+struct P { x } p.x
+xs |> f()
+|x| x
 `
 
 	tests := []struct {
@@ -122,6 +128,39 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.STRING, "say \"hi\"\n"},
+		{token.STRING, "a\\b\\q"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RBRACKET, "]"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "a"},
+		{token.RBRACE, "}"},
+		{token.STRUCT, "struct"},
+		{token.IDENT, "P"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.IDENT, "xs"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.BAR, "|"},
+		{token.IDENT, "x"},
+		{token.BAR, "|"},
+		{token.IDENT, "x"},
 		{token.EOF, ""},
 	}
 
@@ -141,3 +180,185 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"x=${x + 1}, h=${ {"a": "${y}"}["a"] }!" "\${z}" "${}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "x="},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.TEMPLATE_MIDDLE, ", h="},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.TEMPLATE_HEAD, ""},
+		{token.IDENT, "y"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, "!"},
+		{token.STRING, "${z}"},
+		{token.TEMPLATE_HEAD, ""},
+		{token.TEMPLATE_TAIL, ""},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"a\tb"`, "a\tb"},
+		{`"a\rb"`, "a\rb"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\${x}"`, "${x}"},
+		{`"\$"`, "$"},
+		{`"a\qb"`, `a\qb`},
+		{`"\\n"`, `\n`},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("%s: tokentype wrong. expected=%q, got=%q",
+				tt.input, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("%s: literal wrong. expected=%q, got=%q",
+				tt.input, tt.expected, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{`"abc`, []token.Token{{Type: token.ILLEGAL, Literal: `"abc`}}},
+		{`"abc\`, []token.Token{{Type: token.ILLEGAL, Literal: `"abc\`}}},
+		{`"a${x}b`, []token.Token{
+			{Type: token.TEMPLATE_HEAD, Literal: "a"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.ILLEGAL, Literal: "}b"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, expected := range append(tt.expected, token.Token{Type: token.EOF}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("%q: token %d wrong. expected=%s(%q), got=%s(%q)",
+					tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  "two words" +
+	add(x)`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"two words", 2, 3},
+		{"+", 2, 15},
+		{"add", 3, 2},
+		{"(", 3, 5},
+		{"x", 3, 6},
+		{")", 3, 7},
+		{"", 3, 8},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%d:%d, got=%d:%d",
+				i, tok.Literal, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 5; // trailing  
+x / 2 //
+// last`
+
+	expectedTokens := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.SLASH, token.INT, token.EOF,
+	}
+	expectedComments := []token.Token{
+		{Type: token.COMMENT, Literal: "// leading", Line: 1, Column: 1},
+		{Type: token.COMMENT, Literal: "// trailing", Line: 2, Column: 12},
+		{Type: token.COMMENT, Literal: "//", Line: 3, Column: 7},
+		{Type: token.COMMENT, Literal: "// last", Line: 4, Column: 1},
+	}
+
+	l := New(input)
+
+	for i, expected := range expectedTokens {
+		tok := l.NextToken()
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expectedComments), len(comments))
+	}
+	for i, expected := range expectedComments {
+		if comments[i] != expected {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, expected, comments[i])
+		}
+	}
+}
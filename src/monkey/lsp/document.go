@@ -0,0 +1,240 @@
+package lsp
+
+import (
+	"monkey/analysis"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is an open document with the result of parsing and resolving it.
+// When it does not parse, program and info are nil
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	parser  *parser.Parser
+	program *ast.Program
+	info    *analysis.Info
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	d.parser = parser.New(lexer.New(text))
+	program := d.parser.ParseProgram()
+	if len(d.parser.Errors()) == 0 {
+		d.program = program
+		d.info = analysis.Resolve(program)
+	}
+
+	return d
+}
+
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.lines) {
+		return ""
+	}
+	return d.lines[n]
+}
+
+// position converts the 1-based line and byte column of a token to a
+// protocol position
+func (d *document) position(line, column int) Position {
+	text := d.line(line - 1)
+
+	offset := column - 1
+	if offset > len(text) {
+		offset = len(text)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return Position{Line: line - 1, Character: utf16Len(text[:offset])}
+}
+
+// offset converts pos to a 0-based byte offset into its line
+func (d *document) offset(pos Position) int {
+	text := d.line(pos.Line)
+
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(text)
+}
+
+// identRange returns the range of ident
+func (d *document) identRange(ident *ast.Identifier) Range {
+	start := d.position(ident.Token.Line, ident.Token.Column)
+	end := d.position(ident.Token.Line, ident.Token.Column+len(ident.Value))
+	return Range{Start: start, End: end}
+}
+
+// wordRange returns the range from a token position to the end of the word
+// there, or of the single character when it is not in a word
+func (d *document) wordRange(line, column int) Range {
+	text := d.line(line - 1)
+
+	end := column - 1
+	for end < len(text) && isLetter(text[end]) {
+		end++
+	}
+	if end == column-1 && end < len(text) {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	return Range{Start: d.position(line, column), End: d.position(line, end+1)}
+}
+
+// end returns the position after the last character of the document
+func (d *document) end() Position {
+	last := len(d.lines) - 1
+	return Position{Line: last, Character: utf16Len(d.lines[last])}
+}
+
+// identAt returns the identifier at pos, bound or not
+func (d *document) identAt(pos Position) *ast.Identifier {
+	if d.info == nil {
+		return nil
+	}
+	offset := d.offset(pos)
+
+	at := func(ident *ast.Identifier) bool {
+		start := ident.Token.Column - 1
+		return ident.Token.Line-1 == pos.Line && start <= offset && offset <= start+len(ident.Value)
+	}
+
+	for ident := range d.info.Defs {
+		if at(ident) {
+			return ident
+		}
+	}
+	for ident := range d.info.Uses {
+		if at(ident) {
+			return ident
+		}
+	}
+	for _, ident := range d.info.Unresolved {
+		if at(ident) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// bindingAt returns the binding of the identifier at pos
+func (d *document) bindingAt(pos Position) (*ast.Identifier, *analysis.Binding) {
+	ident := d.identAt(pos)
+	if ident == nil {
+		return nil, nil
+	}
+
+	if binding, ok := d.info.Defs[ident]; ok {
+		return ident, binding
+	}
+	return ident, d.info.Uses[ident]
+}
+
+// prefixAt returns the part of the word before pos
+func (d *document) prefixAt(pos Position) string {
+	text := d.line(pos.Line)
+	offset := d.offset(pos)
+
+	start := offset
+	for start > 0 && isLetter(text[start-1]) {
+		start--
+	}
+	return text[start:offset]
+}
+
+// renamed returns the text of d with each of refs replaced by name
+func (d *document) renamed(refs []*ast.Identifier, name string) string {
+	sorted := append([]*ast.Identifier{}, refs...)
+	sort.Slice(sorted, func(i, j int) bool { return after(sorted[i], sorted[j]) })
+
+	lines := append([]string{}, d.lines...)
+	for _, ref := range sorted {
+		text := lines[ref.Token.Line-1]
+		start := ref.Token.Column - 1
+		lines[ref.Token.Line-1] = text[:start] + name + text[start+len(ref.Value):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resolution describes what each identifier of info refers to, in source
+// order: its own declaration, the declaration at an index of the list, a
+// builtin, or nothing. Renaming a binding keeps the order of identifiers,
+// so the descriptions before and after it differ exactly when the rename
+// captures a name or is captured
+func resolution(info *analysis.Info) []string {
+	idents := append([]*ast.Identifier{}, info.Unresolved...)
+	for ident := range info.Defs {
+		idents = append(idents, ident)
+	}
+	for ident := range info.Uses {
+		idents = append(idents, ident)
+	}
+	sort.Slice(idents, func(i, j int) bool { return after(idents[j], idents[i]) })
+
+	index := make(map[*ast.Identifier]int, len(idents))
+	for i, ident := range idents {
+		index[ident] = i
+	}
+
+	refers := make([]string, len(idents))
+	for i, ident := range idents {
+		if _, ok := info.Defs[ident]; ok {
+			refers[i] = "declaration"
+		} else if binding, ok := info.Uses[ident]; !ok {
+			refers[i] = "unresolved"
+		} else if binding.Def == nil {
+			refers[i] = binding.Kind + " " + binding.Name
+		} else {
+			refers[i] = strconv.Itoa(index[binding.Def])
+		}
+	}
+	return refers
+}
+
+// after reports whether a comes after b in the source
+func after(a, b *ast.Identifier) bool {
+	if a.Token.Line != b.Token.Line {
+		return a.Token.Line > b.Token.Line
+	}
+	return a.Token.Column > b.Token.Column
+}
+
+// isLetter reports whether ch can be part of an identifier, as in the lexer
+func isLetter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// isIdentifier reports whether name can be used as an identifier
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isLetter(name[i]) {
+			return false
+		}
+	}
+	return token.LookupIdent(name) == token.IDENT
+}
@@ -0,0 +1,517 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/wire"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testClient drives a server running in-process over a pair of pipes
+type testClient struct {
+	t      *testing.T
+	w      io.WriteCloser
+	r      *bufio.Reader
+	nextID int
+	done   chan error
+
+	// the diagnostics published last for each document
+	published map[string][]Diagnostic
+}
+
+type testMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func startTest(t *testing.T) *testClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	c := &testClient{t: t, w: inW, r: bufio.NewReader(outR), done: make(chan error, 1),
+		published: make(map[string][]Diagnostic)}
+	go func() {
+		err := Serve(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+
+	c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+func (c *testClient) send(msg interface{}) {
+	if err := wire.Write(c.w, msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) read() testMessage {
+	content, err := wire.Read(c.r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	var msg testMessage
+	if err := json.Unmarshal(content, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// call sends a request and decodes its result into result, returning the
+// error the server responded with
+func (c *testClient) call(method string, params interface{}, result interface{}) *Error {
+	c.nextID++
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+
+	msg := c.read()
+	if msg.ID == nil || *msg.ID != c.nextID {
+		c.t.Fatalf("%s: expected the response to request %d, got %+v", method, c.nextID, msg)
+	}
+
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatalf("%s: decoding %s: %v", method, msg.Result, err)
+		}
+	}
+	return nil
+}
+
+// readDiagnostics reads the diagnostics the server publishes after a
+// document changes. The pipes are unbuffered, so they must be read before
+// the next request is sent
+func (c *testClient) readDiagnostics() {
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %+v", msg)
+	}
+
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	c.published[params.URI] = params.Diagnostics
+}
+
+func (c *testClient) open(uri, text string) {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text},
+	})
+	c.readDiagnostics()
+}
+
+func (c *testClient) change(uri, text string) {
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+	c.readDiagnostics()
+}
+
+func (c *testClient) exit() error {
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func rng(line, start, end int) Range {
+	return Range{Start: Position{line, start}, End: Position{line, end}}
+}
+
+const testURI = "file:///test.monkey"
+
+const testSource = `let total = 10;
+fn add(a, b = 1) {
+    a + b
+}
+let result = add(total, 2);
+struct Point { x, y  fn sum() { self.x + self.y } }
+let name = "monkey";
+puts(len(name), result);
+`
+
+func TestDiagnostics(t *testing.T) {
+	c := startTest(t)
+	defer c.exit()
+
+	c.open(testURI, "let f = fn() {\n    let unused = 1;\n    return 2;\n    3\n};\nf(1)\n")
+	got := c.published[testURI]
+
+	expected := []Diagnostic{
+		{Range: rng(1, 8, 14), Severity: SeverityWarning, Code: "unused", Source: "monkey vet",
+			Message: "unused declared but not used"},
+		{Range: rng(3, 4, 5), Severity: SeverityWarning, Code: "unreachable", Source: "monkey vet",
+			Message: "unreachable code"},
+		{Range: rng(5, 0, 1), Severity: SeverityWarning, Code: "arity", Source: "monkey vet",
+			Message: "wrong number of arguments to f. got=1, want=0"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong diagnostics.\nexpected=%+v\ngot=%+v", expected, got)
+	}
+
+	c.change(testURI, "let x = 1;\nlet = 2;")
+	got = c.published[testURI]
+	if len(got) == 0 || got[0].Severity != SeverityError || got[0].Range.Start != (Position{1, 4}) {
+		t.Errorf("wrong parse error diagnostics. got=%+v", got)
+	}
+
+	c.change(testURI, "let x = 1;\nx")
+	if got := c.published[testURI]; len(got) != 0 {
+		t.Errorf("expected diagnostics to be cleared. got=%+v", got)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}})
+	c.readDiagnostics()
+	if got, ok := c.published[testURI]; !ok || len(got) != 0 {
+		t.Errorf("expected diagnostics to be cleared on close. got=%+v", got)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := startTest(t)
+	defer c.exit()
+	c.open(testURI, testSource)
+
+	tests := []struct {
+		line, character int
+		expected        string
+		expectedRange   Range
+	}{
+		{0, 5, "(let) total: INTEGER", rng(0, 4, 9)},
+		{4, 18, "(let) total: INTEGER", rng(4, 17, 22)},
+		{4, 14, "(fn) add(a, b = 1)", rng(4, 13, 16)},
+		{2, 4, "(parameter) a", rng(2, 4, 5)},
+		{7, 6, "(builtin) len(value)", rng(7, 5, 8)},
+		{7, 0, "(builtin) puts(values...)", rng(7, 0, 4)},
+		{5, 8, "(struct) Point { x, y, sum() }", rng(5, 7, 12)},
+		{6, 6, "(let) name: STRING", rng(6, 4, 8)},
+		{4, 5, "(let) result", rng(4, 4, 10)},
+	}
+
+	for _, tt := range tests {
+		var hover *Hover
+		if err := c.call("textDocument/hover", at(testURI, tt.line, tt.character), &hover); err != nil {
+			t.Fatal(err)
+		}
+		if hover == nil {
+			t.Errorf("%d:%d: no hover", tt.line, tt.character)
+			continue
+		}
+
+		expected := "```monkey\n" + tt.expected + "\n```"
+		if hover.Contents.Value != expected || hover.Range != tt.expectedRange {
+			t.Errorf("%d:%d: wrong hover.\nexpected=%q %v\ngot=%q %v", tt.line, tt.character,
+				expected, tt.expectedRange, hover.Contents.Value, hover.Range)
+		}
+	}
+
+	var hover *Hover
+	c.call("textDocument/hover", at(testURI, 1, 17), &hover)
+	if hover != nil {
+		t.Errorf("expected no hover outside identifiers. got=%+v", hover)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := startTest(t)
+	defer c.exit()
+	c.open(testURI, testSource)
+
+	var location *Location
+	if err := c.call("textDocument/definition", at(testURI, 4, 19), &location); err != nil {
+		t.Fatal(err)
+	}
+	if location == nil || location.URI != testURI || location.Range != rng(0, 4, 9) {
+		t.Errorf("wrong definition of total. got=%+v", location)
+	}
+
+	c.call("textDocument/definition", at(testURI, 2, 8), &location)
+	if location == nil || location.Range != rng(1, 10, 11) {
+		t.Errorf("wrong definition of parameter b. got=%+v", location)
+	}
+
+	location = nil
+	c.call("textDocument/definition", at(testURI, 7, 6), &location)
+	if location != nil {
+		t.Errorf("expected no definition for a builtin. got=%+v", location)
+	}
+
+	tests := []struct {
+		line, character    int
+		includeDeclaration bool
+		expected           []Range
+	}{
+		{0, 6, true, []Range{rng(0, 4, 9), rng(4, 17, 22)}},
+		{0, 6, false, []Range{rng(4, 17, 22)}},
+		{1, 7, true, []Range{rng(1, 7, 8), rng(2, 4, 5)}},
+		{7, 20, true, []Range{rng(4, 4, 10), rng(7, 16, 22)}},
+	}
+
+	for _, tt := range tests {
+		params := ReferenceParams{TextDocumentPositionParams: at(testURI, tt.line, tt.character)}
+		params.Context.IncludeDeclaration = tt.includeDeclaration
+
+		var locations []Location
+		if err := c.call("textDocument/references", params, &locations); err != nil {
+			t.Fatal(err)
+		}
+
+		got := []Range{}
+		for _, loc := range locations {
+			got = append(got, loc.Range)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%d:%d: wrong references.\nexpected=%v\ngot=%v", tt.line, tt.character, tt.expected, got)
+		}
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c := startTest(t)
+	defer c.exit()
+	c.open(testURI, testSource+"let [first_, second_] = [1, 2];\n")
+
+	var symbols []SymbolInformation
+	err := c.call("textDocument/documentSymbol",
+		DocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, sym := range symbols {
+		got = append(got, fmt.Sprintf("%s/%s:%d@%d", sym.ContainerName, sym.Name, sym.Kind, sym.Location.Range.Start.Line))
+	}
+	expected := []string{
+		"/total:13@0",
+		"/add:12@1",
+		"/result:13@4",
+		"/Point:23@5",
+		"Point/x:8@5",
+		"Point/y:8@5",
+		"Point/sum:6@5",
+		"/name:13@6",
+		"/first_:13@8",
+		"/second_:13@8",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong symbols.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := startTest(t)
+	defer c.exit()
+	c.open(testURI, testSource+"re")
+
+	var items []CompletionItem
+	if err := c.call("textDocument/completion", at(testURI, 8, 2), &items); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, item := range items {
+		got = append(got, fmt.Sprintf("%s:%d", item.Label, item.Kind))
+	}
+	expected := []string{"repeat:3", "replace:3", "rest:3", "result:6", "return:14"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong completions.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	c.call("textDocument/completion", at(testURI, 4, 15), &items)
+	if len(items) != 1 || items[0].Label != "add" || items[0].Detail != "(fn) add(a, b = 1)" {
+		t.Errorf("wrong completion for ad. got=%+v", items)
+	}
+}
+
+func TestRename(t *testing.T) {
+	c := startTest(t)
+	defer c.exit()
+	c.open(testURI, "let count = 1;\ncount = count + 1;\nlet f = fn(count) { count };\nf(count)\n")
+
+	var edit WorkspaceEdit
+	if err := c.call("textDocument/rename", RenameParams{at(testURI, 1, 9), "n"}, &edit); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []Range{}
+	for _, e := range edit.Changes[testURI] {
+		if e.NewText != "n" {
+			t.Errorf("wrong new text %q", e.NewText)
+		}
+		got = append(got, e.Range)
+	}
+	expected := []Range{rng(0, 4, 9), rng(1, 0, 5), rng(1, 8, 13), rng(3, 2, 7)}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong rename edits.\nexpected=%v\ngot=%v", expected, got)
+	}
+
+	errorTests := []struct {
+		params RenameParams
+		code   int
+	}{
+		{RenameParams{at(testURI, 0, 5), "let"}, InvalidParams},
+		{RenameParams{at(testURI, 0, 5), "a b"}, InvalidParams},
+		{RenameParams{at(testURI, 0, 11), "x"}, RequestFailed},
+	}
+	for _, tt := range errorTests {
+		err := c.call("textDocument/rename", tt.params, nil)
+		if err == nil || err.Code != tt.code {
+			t.Errorf("%+v: expected error code %d, got %v", tt.params, tt.code, err)
+		}
+	}
+
+	// renames that would make a name refer to a different binding
+	c.open(testURI, "let a = 1; let b = 2; let f = fn(x) { x + a };\nlen(a)\n")
+	captures := []RenameParams{
+		{at(testURI, 0, 4), "b"},   // a is shadowed by the later b
+		{at(testURI, 0, 4), "x"},   // the use of a in f is captured by x
+		{at(testURI, 0, 33), "a"},  // x captures the use of a in f
+		{at(testURI, 0, 4), "len"}, // the call of len is captured by a
+		{at(testURI, 0, 4), "_"},   // _ binds nothing
+	}
+	for _, params := range captures {
+		err := c.call("textDocument/rename", params, nil)
+		if err == nil || err.Code != RequestFailed {
+			t.Errorf("rename to %q: expected error code %d, got %v", params.NewName, RequestFailed, err)
+		}
+	}
+
+	if err := c.call("textDocument/rename", RenameParams{at(testURI, 0, 4), "c"}, &edit); err != nil {
+		t.Fatalf("rename without capture failed: %v", err)
+	}
+	if len(edit.Changes[testURI]) != 3 {
+		t.Errorf("wrong number of rename edits. got=%+v", edit.Changes[testURI])
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := startTest(t)
+	defer c.exit()
+	c.open(testURI, "let x=1\nputs( x )")
+
+	params := DocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}}
+	var edits []TextEdit
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TextEdit{{Range: Range{End: Position{1, 9}}, NewText: "let x = 1;\nputs(x);\n"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits.\nexpected=%+v\ngot=%+v", expected, edits)
+	}
+
+	c.open(testURI, "let x = 1;\nputs(x);\n")
+	c.call("textDocument/formatting", params, &edits)
+	if len(edits) != 0 {
+		t.Errorf("expected no edits for a formatted document. got=%+v", edits)
+	}
+
+	c.open(testURI, "let = 1")
+	if err := c.call("textDocument/formatting", params, nil); err == nil || err.Code != RequestFailed {
+		t.Errorf("expected formatting to fail on a parse error. got=%v", err)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(inR, outW)
+		outW.Close()
+	}()
+	c := &testClient{t: t, w: inW, r: bufio.NewReader(outR), published: make(map[string][]Diagnostic)}
+
+	if err := c.call("textDocument/hover", at(testURI, 0, 0), nil); err == nil || err.Code != ServerNotInitialized {
+		t.Errorf("expected a request before initialize to fail. got=%v", err)
+	}
+
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	c.call("initialize", map[string]interface{}{}, &result)
+	for _, capability := range []string{"hoverProvider", "definitionProvider", "referencesProvider",
+		"documentSymbolProvider", "completionProvider", "renameProvider", "documentFormattingProvider"} {
+		if result.Capabilities[capability] == nil {
+			t.Errorf("capability %s not advertised", capability)
+		}
+	}
+
+	if err := c.call("textDocument/nope", nil, nil); err == nil || err.Code != MethodNotFound {
+		t.Errorf("expected method not found. got=%v", err)
+	}
+	if err := c.call("textDocument/hover", at("file:///closed.monkey", 0, 0), nil); err == nil || err.Code != InvalidParams {
+		t.Errorf("expected a closed document to be an error. got=%v", err)
+	}
+
+	handlers["test/panic"] = func(s *server, params json.RawMessage) (interface{}, error) {
+		panic("boom")
+	}
+	defer delete(handlers, "test/panic")
+	if err := c.call("test/panic", nil, nil); err == nil || err.Code != InternalError || err.Message != "internal error: boom" {
+		t.Errorf("expected a panicking request to fail with an internal error. got=%v", err)
+	}
+
+	io.WriteString(inW, "Content-Length: 5\r\n\r\n{oops")
+	if msg := c.read(); msg.Error == nil || msg.Error.Code != ParseError {
+		t.Errorf("expected a parse error response. got=%+v", msg)
+	}
+
+	c.notify("exit", nil)
+	if err := <-done; err != ErrNoShutdown {
+		t.Errorf("expected exit without shutdown to fail. got=%v", err)
+	}
+
+	c = startTest(t)
+	if err := c.exit(); err != nil {
+		t.Errorf("unexpected error on exit: %v", err)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	d := newDocument(testURI, "let s = \"héllo😀\"; s\nx")
+
+	// s is at byte column 23 of the first line, after a two byte é and a
+	// four byte emoji, which take one and two UTF-16 code units
+	if got := d.position(1, 23); got != (Position{0, 19}) {
+		t.Errorf("wrong position. got=%+v", got)
+	}
+	if got := d.offset(Position{0, 19}); got != 22 {
+		t.Errorf("wrong offset. got=%d", got)
+	}
+
+	ident, binding := d.bindingAt(Position{0, 19})
+	if ident == nil || binding == nil || binding.Def.Token.Column != 5 {
+		t.Errorf("wrong binding at s. got=%v %+v", ident, binding)
+	}
+	if ident := d.identAt(Position{1, 0}); ident == nil || ident.Value != "x" {
+		t.Errorf("unresolved identifier not found. got=%v", ident)
+	}
+	if !strings.HasPrefix(d.prefixAt(Position{1, 1}), "x") {
+		t.Errorf("wrong prefix. got=%q", d.prefixAt(Position{1, 1}))
+	}
+}
@@ -0,0 +1,559 @@
+// Package lsp implements a Language Server Protocol server for Monkey,
+// giving editors diagnostics, hover, navigation, completion, rename and
+// formatting for .monkey files
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"monkey/analysis"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/format"
+	"monkey/object"
+	"monkey/token"
+	"monkey/wire"
+	"sort"
+	"strings"
+)
+
+// ErrNoShutdown is returned by Serve when the client exits without asking
+// the server to shut down first
+var ErrNoShutdown = errors.New("exit without shutdown")
+
+// server holds the state of one connection
+type server struct {
+	out         io.Writer
+	initialized bool
+	shutdown    bool
+	documents   map[string]*document
+}
+
+type handler func(s *server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*server).initialize,
+	"shutdown":                    (*server).shutdownRequest,
+	"textDocument/didOpen":        (*server).didOpen,
+	"textDocument/didChange":      (*server).didChange,
+	"textDocument/didClose":       (*server).didClose,
+	"textDocument/hover":          (*server).hover,
+	"textDocument/definition":     (*server).definition,
+	"textDocument/references":     (*server).references,
+	"textDocument/documentSymbol": (*server).documentSymbol,
+	"textDocument/completion":     (*server).completion,
+	"textDocument/rename":         (*server).rename,
+	"textDocument/formatting":     (*server).formatting,
+}
+
+// Serve answers the requests read from in, writing responses and
+// notifications to out, until the client sends exit
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{out: out, documents: make(map[string]*document)}
+	r := bufio.NewReader(in)
+
+	for {
+		content, err := wire.Read(r)
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			if err := s.reply(nil, nil, errorf(ParseError, "%s", err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+
+		result, err := s.handle(&req)
+		if req.ID == nil {
+			continue // notifications get no response, not even errors
+		}
+		if err := s.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// handle runs the handler for req. A panic in it fails only this request
+func (s *server) handle(req *request) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, errorf(InternalError, "internal error: %v", r)
+		}
+	}()
+
+	h, ok := handlers[req.Method]
+	switch {
+	case req.Method == "initialized":
+		return nil, nil
+	case !s.initialized && req.Method != "initialize":
+		return nil, errorf(ServerNotInitialized, "server not initialized")
+	case s.shutdown:
+		return nil, errorf(InvalidRequest, "server is shutting down")
+	case !ok:
+		return nil, errorf(MethodNotFound, "method not found: %s", req.Method)
+	}
+
+	return h(s, req.Params)
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}, err error) error {
+	if err == nil {
+		return wire.Write(s.out, &response{JSONRPC: "2.0", ID: id, Result: result})
+	}
+
+	rpcErr, ok := err.(*Error)
+	if !ok {
+		rpcErr = errorf(RequestFailed, "%s", err)
+	}
+	return wire.Write(s.out, &errorResponse{JSONRPC: "2.0", ID: id, Error: rpcErr})
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return wire.Write(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return errorf(InvalidParams, "%s", err)
+	}
+	return nil
+}
+
+func (s *server) document(uri string) (*document, error) {
+	d, ok := s.documents[uri]
+	if !ok {
+		return nil, errorf(InvalidParams, "document not open: %s", uri)
+	}
+	return d, nil
+}
+
+func (s *server) initialize(params json.RawMessage) (interface{}, error) {
+	s.initialized = true
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           1, // the whole document on each change
+			"hoverProvider":              true,
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"documentSymbolProvider":     true,
+			"renameProvider":             true,
+			"documentFormattingProvider": true,
+			"completionProvider":         map[string]interface{}{},
+		},
+		"serverInfo": map[string]string{"name": "monkey"},
+	}, nil
+}
+
+func (s *server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+
+	return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	delete(s.documents, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics",
+		PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+}
+
+// update replaces the text of a document and publishes its diagnostics
+func (s *server) update(uri, text string) error {
+	d := newDocument(uri, text)
+	s.documents[uri] = d
+
+	return s.notify("textDocument/publishDiagnostics",
+		PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics(d)})
+}
+
+// diagnostics returns the parse errors of d or, when it parses, the
+// problems the linter finds
+func diagnostics(d *document) []Diagnostic {
+	result := []Diagnostic{}
+
+	if d.program == nil {
+		tokens := d.parser.ErrorTokens()
+		for i, msg := range d.parser.Errors() {
+			result = append(result, Diagnostic{
+				Range:    d.wordRange(tokens[i].Line, tokens[i].Column),
+				Severity: SeverityError,
+				Source:   "monkey",
+				Message:  msg,
+			})
+		}
+		return result
+	}
+
+	for _, diag := range analysis.Vet(d.program, nil) {
+		result = append(result, Diagnostic{
+			Range:    d.wordRange(diag.Line, diag.Column),
+			Severity: SeverityWarning,
+			Code:     diag.Rule,
+			Source:   "monkey vet",
+			Message:  diag.Message,
+		})
+	}
+	return result
+}
+
+func (s *server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	ident, binding := d.bindingAt(p.Position)
+	if binding == nil {
+		return nil, nil
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + describe(d.info, binding) + "\n```"},
+		Range:    d.identRange(ident),
+	}, nil
+}
+
+// describe returns the hover text of a binding: its kind and name, with
+// the signature of functions and the inferred type of let bindings
+func describe(info *analysis.Info, binding *analysis.Binding) string {
+	if binding.Kind == analysis.BUILTIN {
+		signature, _ := evaluator.BuiltinSignature(binding.Name)
+		return "(builtin) " + signature
+	}
+
+	if binding.Function != nil {
+		params := []string{}
+		for _, param := range binding.Function.Parameters {
+			params = append(params, param.String())
+		}
+		return "(" + binding.Kind + ") " + binding.Name + "(" + strings.Join(params, ", ") + ")"
+	}
+
+	switch decl := binding.Decl.(type) {
+	case *ast.LetStatement:
+		if decl.Name == binding.Def && !binding.Assigned {
+			if kind := infer(info, decl.Value, 0); kind != "" {
+				return "(" + binding.Kind + ") " + binding.Name + ": " + kind
+			}
+		}
+	case *ast.StructStatement:
+		members := []string{}
+		for _, field := range decl.Fields {
+			members = append(members, field.Value)
+		}
+		for _, method := range decl.Methods {
+			members = append(members, method.Name.Value+"()")
+		}
+		return "(struct) " + binding.Name + " { " + strings.Join(members, ", ") + " }"
+	}
+
+	return "(" + binding.Kind + ") " + binding.Name
+}
+
+// infer returns the type of the value e evaluates to, as named by the
+// evaluator, or "" when it cannot be told without running the program
+func infer(info *analysis.Info, e ast.Expression, depth int) string {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGEROBJ
+	case *ast.Boolean:
+		return object.BOOLEANOBJ
+	case *ast.StringLiteral, *ast.InterpolatedString:
+		return object.STRINGOBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAYOBJ
+	case *ast.HashLiteral:
+		return object.HASHOBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTIONOBJ
+	case *ast.ImportExpression:
+		return object.MODULEOBJ
+	case *ast.StructLiteral:
+		if member, ok := e.Type.(*ast.MemberExpression); ok {
+			return member.Member.Value
+		}
+		return e.Type.String()
+	case *ast.PrefixExpression:
+		if e.Operator == "!" {
+			return object.BOOLEANOBJ
+		}
+		return infer(info, e.Right, depth)
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "==", "!=", "<", ">":
+			return object.BOOLEANOBJ
+		}
+		left, right := infer(info, e.Left, depth), infer(info, e.Right, depth)
+		if left == right {
+			return left
+		}
+	case *ast.Identifier:
+		// follow let bindings of other names, as far as a cycle allows
+		binding := info.Uses[e]
+		if binding == nil || binding.Assigned || depth > 8 {
+			return ""
+		}
+		if let, ok := binding.Decl.(*ast.LetStatement); ok && let.Name == binding.Def {
+			return infer(info, let.Value, depth+1)
+		}
+		if binding.Kind == analysis.STRUCT {
+			return object.STRUCTTYPEOBJ
+		}
+	}
+	return ""
+}
+
+func (s *server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	_, binding := d.bindingAt(p.Position)
+	if binding == nil || binding.Def == nil {
+		return nil, nil
+	}
+	return &Location{URI: d.uri, Range: d.identRange(binding.Def)}, nil
+}
+
+func (s *server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	_, binding := d.bindingAt(p.Position)
+	if binding == nil {
+		return locations, nil
+	}
+
+	for _, ref := range d.info.References(binding) {
+		if ref == binding.Def && !p.Context.IncludeDeclaration {
+			continue
+		}
+		locations = append(locations, Location{URI: d.uri, Range: d.identRange(ref)})
+	}
+	return locations, nil
+}
+
+func (s *server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []SymbolInformation{}
+	if d.program == nil {
+		return symbols, nil
+	}
+
+	add := func(ident *ast.Identifier, kind int, container string) {
+		symbols = append(symbols, SymbolInformation{
+			Name:          ident.Value,
+			Kind:          kind,
+			Location:      Location{URI: d.uri, Range: d.identRange(ident)},
+			ContainerName: container,
+		})
+	}
+
+	for _, stmt := range d.program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			kind := SymbolVariable
+			if stmt.Token.Type == token.CONST {
+				kind = SymbolConstant
+			}
+			if _, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				kind = SymbolFunction
+			}
+
+			for _, binding := range d.info.Bindings {
+				if binding.Decl == stmt {
+					add(binding.Def, kind, "")
+				}
+			}
+		case *ast.FunctionStatement:
+			add(stmt.Name, SymbolFunction, "")
+		case *ast.StructStatement:
+			add(stmt.Name, SymbolStruct, "")
+			for _, field := range stmt.Fields {
+				add(field, SymbolField, stmt.Name.Value)
+			}
+			for _, method := range stmt.Methods {
+				add(method.Name, SymbolMethod, stmt.Name.Value)
+			}
+		}
+	}
+	return symbols, nil
+}
+
+func (s *server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := d.prefixAt(p.Position)
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if strings.HasPrefix(item.Label, prefix) && !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	if d.info != nil {
+		for _, binding := range d.info.Bindings {
+			kind := CompletionVariable
+			switch {
+			case binding.Function != nil:
+				kind = CompletionFunction
+			case binding.Kind == analysis.CONST:
+				kind = CompletionConstant
+			case binding.Kind == analysis.STRUCT:
+				kind = CompletionStruct
+			}
+			add(CompletionItem{Label: binding.Name, Kind: kind, Detail: describe(d.info, binding)})
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		signature, _ := evaluator.BuiltinSignature(name)
+		add(CompletionItem{Label: name, Kind: CompletionFunction, Detail: signature})
+	}
+	for _, keyword := range token.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: CompletionKeyword})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items, nil
+}
+
+func (s *server) rename(params json.RawMessage) (interface{}, error) {
+	var p RenameParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isIdentifier(p.NewName) {
+		return nil, errorf(InvalidParams, "%q is not a valid identifier", p.NewName)
+	}
+	ident, binding := d.bindingAt(p.Position)
+	switch {
+	case ident == nil:
+		return nil, errorf(RequestFailed, "no identifier to rename")
+	case binding == nil:
+		return nil, errorf(RequestFailed, "undefined identifier %s", ident.Value)
+	case binding.Def == nil:
+		return nil, errorf(RequestFailed, "cannot rename %s %s", binding.Kind, binding.Name)
+	}
+
+	refs := d.info.References(binding)
+	renamed := newDocument(d.uri, d.renamed(refs, p.NewName))
+	if renamed.info == nil || !sameStrings(resolution(d.info), resolution(renamed.info)) {
+		return nil, errorf(RequestFailed, "renaming %s to %s would change what other names refer to",
+			binding.Name, p.NewName)
+	}
+
+	edits := []TextEdit{}
+	for _, ref := range refs {
+		edits = append(edits, TextEdit{Range: d.identRange(ref), NewText: p.NewName})
+	}
+	return &WorkspaceEdit{Changes: map[string][]TextEdit{d.uri: edits}}, nil
+}
+
+func (s *server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(d.text)
+	if err != nil {
+		return nil, errorf(RequestFailed, "cannot format: %s", err)
+	}
+	if formatted == d.text {
+		return []TextEdit{}, nil
+	}
+
+	whole := Range{Start: Position{}, End: d.end()}
+	return []TextEdit{{Range: whole, NewText: formatted}}, nil
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
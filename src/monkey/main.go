@@ -1,19 +1,315 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/analysis"
+	"monkey/dap"
+	"monkey/debugger"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/lsp"
+	"monkey/parser"
 	"monkey/repl"
 	"os"
+	"os/signal"
 	"os/user"
+	"strings"
+	"syscall"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "fmt":
+			os.Exit(formatFiles(os.Args[2:]))
+		case "vet":
+			os.Exit(vetFiles(os.Args[2:]))
+		case "debug":
+			os.Exit(debug(os.Args[2:]))
+		case "dap":
+			os.Exit(debugAdapter(os.Args[2:]))
+		case "lsp":
+			// stdout carries the protocol, so errors go to stderr
+			if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
 		user.Username)
-	fmt.Printf("Feel free to type in commands\n")
+	fmt.Printf("Feel free to type in commands, or :help for REPL commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// serve runs the REPL for connections on a socket until interrupted. A
+// session can run any code, so the socket must be local unless
+// -allow-remote is given
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", "tcp:localhost:7777", "address to listen on, unix:/path or tcp:host:port")
+	shared := flags.Bool("shared", false, "evaluate all sessions in one environment")
+	maxSessions := flags.Int("max-sessions", 0, "sessions allowed at once, 0 for no limit")
+	allowFiles := flags.Bool("allow-files", false, "let sessions read and write files with :load, :save and import")
+	allowRemote := flags.Bool("allow-remote", false, "let -listen use an address reachable from other machines")
+	flags.Parse(args)
+
+	l, err := repl.Listen(*listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !repl.IsLocal(l) && !*allowRemote {
+		l.Close()
+		fmt.Fprintf(os.Stderr, "%s is reachable from other machines, use a loopback address or -allow-remote\n", *listen)
+		os.Exit(1)
+	}
+
+	// closing the listener removes a unix socket file
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		l.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "Monkey REPL listening on %s\n", *listen)
+	srv := &repl.Server{Shared: *shared, MaxSessions: *maxSessions, AllowFiles: *allowFiles}
+	srv.Serve(l)
+}
+
+// formatFiles formats the files named in args, or standard input, and
+// returns the exit status: 1 when --check finds unformatted files, 2 when
+// a file could not be formatted
+func formatFiles(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of printing it")
+	list := flags.Bool("l", false, "list the files whose formatting differs")
+	check := flags.Bool("check", false, "exit with status 1 if any file is not formatted")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		formatted, err := format.Source(string(src))
+		if err != nil {
+			printFormatError("<stdin>", err)
+			return 2
+		}
+		if *check && formatted != string(src) {
+			return 1
+		}
+		if !*check {
+			fmt.Print(formatted)
+		}
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			continue
+		}
+
+		formatted, err := format.Source(string(src))
+		if err != nil {
+			printFormatError(path, err)
+			status = 2
+			continue
+		}
+		changed := formatted != string(src)
+
+		if *list && changed {
+			fmt.Println(path)
+		}
+		if *check && changed && status == 0 {
+			status = 1
+		}
+		if *write && changed {
+			info, err := os.Stat(path)
+			if err == nil {
+				err = ioutil.WriteFile(path, []byte(formatted), info.Mode())
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 2
+			}
+		}
+		if !*list && !*write && !*check {
+			fmt.Print(formatted)
+		}
+	}
+
+	return status
+}
+
+func printFormatError(path string, err error) {
+	if parseErr, ok := err.(*format.ParseError); ok {
+		for _, msg := range parseErr.Errors {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+}
+
+// vetFiles reports the diagnostics for the files named in args and returns
+// the exit status: 1 when there are diagnostics, 2 when a file could not be
+// read or parsed
+func vetFiles(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	disable := flags.String("disable", "", "comma-separated rules to skip: "+strings.Join(analysis.Rules, ", "))
+	flags.Parse(args)
+
+	known := map[string]bool{}
+	for _, rule := range analysis.Rules {
+		known[rule] = true
+	}
+
+	disabled := map[string]bool{}
+	for _, rule := range strings.Split(*disable, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if !known[rule] {
+			fmt.Fprintf(os.Stderr, "unknown rule %q\n", rule)
+			return 2
+		}
+		disabled[rule] = true
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			continue
+		}
+
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+			status = 2
+			continue
+		}
+
+		for _, d := range analysis.Vet(program, disabled) {
+			fmt.Printf("%s:%s\n", path, d)
+			if status == 0 {
+				status = 1
+			}
+		}
+	}
+
+	return status
+}
+
+// debug runs the file named in args under the debugger and returns the exit
+// status, 2 when the file could not be read or parsed
+func debug(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey debug FILE")
+		return 2
+	}
+
+	src, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], msg)
+		}
+		return 2
+	}
+
+	fmt.Println("Debugging", args[0]+", type help for commands")
+	debugger.Start(program, string(src), os.Stdin, os.Stdout)
+	return 0
+}
+
+// debugAdapter serves the Debug Adapter Protocol on standard input and
+// output or, with -listen, to each connection on a socket until
+// interrupted. A client can run any program, so the socket must be local
+// unless -allow-remote is given
+func debugAdapter(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	listen := flags.String("listen", "", "address to listen on, unix:/path or tcp:host:port, instead of standard input and output")
+	allowRemote := flags.Bool("allow-remote", false, "let -listen use an address reachable from other machines")
+	flags.Parse(args)
+
+	if *listen == "" {
+		// stdout carries the protocol, so errors go to stderr
+		if err := dap.Serve(os.Stdin, os.Stdout); err != nil && err != io.EOF {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	l, err := repl.Listen(*listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !repl.IsLocal(l) && !*allowRemote {
+		l.Close()
+		fmt.Fprintf(os.Stderr, "%s is reachable from other machines, use a loopback address or -allow-remote\n", *listen)
+		return 1
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		l.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "Monkey debug adapter listening on %s\n", *listen)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return 0
+		}
+		go func() {
+			defer conn.Close()
+			// a panic ends only this connection
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintf(os.Stderr, "internal error: %v\n", r)
+				}
+			}()
+			if err := dap.Serve(conn, conn); err != nil && err != io.EOF {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+}
@@ -1,22 +1,94 @@
 package object
 
+import (
+	"io"
+	"monkey/ast"
+	"os"
+	"sort"
+)
+
 // NewEnclosedEnvironment returns env that encapsulates the inner env
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := newEnvironment()
 	env.outer = outer
+	env.out = outer.out
+	env.depth = outer.depth
+	env.debugger = outer.debugger
+	env.imports = outer.imports
+	env.modules = outer.modules
+	env.noImports = outer.noImports
+	return env
+}
+
+// NewCallEnvironment returns the env for a function call whose closure is
+// outer and which was made from caller. The call prints to the output of
+// the caller and is observed by its debugger, not those of the place the
+// function was defined
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.out = caller.out
+	env.depth = caller.depth + 1
+	env.debugger = caller.debugger
 	return env
 }
 
-// NewEnvironment creates a new environment
+// NewEnvironment creates a new top-level environment, with a module cache
+// of its own
 func NewEnvironment() *Environment {
+	env := newEnvironment()
+	env.modules = NewModuleCache()
+	return env
+}
+
+func newEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	c := make(map[string]bool)
+	return &Environment{store: s, constants: c, outer: nil}
 }
 
 // Environment keep tracks of all names and maps to object
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store     map[string]Object
+	constants map[string]bool
+	outer     *Environment
+	out       io.Writer
+	depth     int
+	debugger  Debugger
+	imports   []string
+	modules   *ModuleCache
+	noImports bool
+}
+
+// ModuleCache holds the modules imported by one interpreter, by absolute
+// path, so each import of a file in it shares one module. It is not safe
+// for concurrent use, like the environments sharing it
+type ModuleCache struct {
+	loaded map[string]*Module
+}
+
+// NewModuleCache returns an empty module cache
+func NewModuleCache() *ModuleCache {
+	return &ModuleCache{loaded: make(map[string]*Module)}
+}
+
+// Get returns the module imported from path, if any
+func (c *ModuleCache) Get(path string) (*Module, bool) {
+	mod, ok := c.loaded[path]
+	return mod, ok
+}
+
+// Set records mod as the module imported from path
+func (c *ModuleCache) Set(path string, mod *Module) {
+	c.loaded[path] = mod
+}
+
+// Debugger observes evaluation. The evaluator calls Step before it
+// evaluates each statement and expression, and Call and Return around each
+// function call and, with a nil node, around evaluating an imported module
+type Debugger interface {
+	Step(node ast.Node, env *Environment)
+	Call(node *ast.CallExpression, name string)
+	Return(node *ast.CallExpression)
 }
 
 // Get returns the object associated with the name
@@ -33,3 +105,126 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Define binds name in env, as a constant if constant is set. It refuses,
+// returning false, to rebind a name that env already holds as a constant
+func (e *Environment) Define(name string, val Object, constant bool) bool {
+	if e.constants[name] {
+		return false
+	}
+
+	e.store[name] = val
+	if constant {
+		e.constants[name] = true
+	}
+	return true
+}
+
+// Assign replaces the value of name in the nearest environment that binds
+// it, reporting whether name was found and whether it could be replaced
+func (e *Environment) Assign(name string, val Object) (found, assigned bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			if env.constants[name] {
+				return true, false
+			}
+			env.store[name] = val
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// IsConst reports whether name is bound as a constant directly in env
+func (e *Environment) IsConst(name string) bool {
+	return e.constants[name]
+}
+
+// Outer returns the enclosing environment, or nil for a top-level one
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Depth returns the number of function calls active in env
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+// Names returns the sorted names bound directly in env
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Output returns the writer that builtins print to, defaulting to os.Stdout
+func (e *Environment) Output() io.Writer {
+	if e.out == nil {
+		return os.Stdout
+	}
+	return e.out
+}
+
+// SetOutput redirects builtin output for env and the environments created
+// inside it from then on, including those of the calls made from it
+func (e *Environment) SetOutput(w io.Writer) {
+	e.out = w
+}
+
+// Debugger returns the debugger observing evaluation in env, if any
+func (e *Environment) Debugger() Debugger {
+	return e.debugger
+}
+
+// SetDebugger attaches d to env and to the environments created inside it
+// from then on, including those of the functions it defines. Setting nil
+// detaches it
+func (e *Environment) SetDebugger(d Debugger) {
+	e.debugger = d
+}
+
+// ImportChain returns the absolute paths of the modules being imported
+// when env was made, outermost first. It is empty for the main program
+func (e *Environment) ImportChain() []string {
+	return e.imports
+}
+
+// SetImportChain records chain as the imports that led to env and the
+// environments created inside it from then on
+func (e *Environment) SetImportChain(chain []string) {
+	e.imports = chain
+}
+
+// Modules returns the cache of the modules imported in env
+func (e *Environment) Modules() *ModuleCache {
+	return e.modules
+}
+
+// SetModules makes env and the environments created inside it from then on
+// import through cache
+func (e *Environment) SetModules(cache *ModuleCache) {
+	e.modules = cache
+}
+
+// ImportsDisabled reports whether import expressions are refused in env
+func (e *Environment) ImportsDisabled() bool {
+	return e.noImports
+}
+
+// DisableImports refuses import expressions in env and the environments
+// created inside it from then on, so programs run in it cannot read files
+func (e *Environment) DisableImports() {
+	e.noImports = true
+}
+
+// File returns the absolute path of the module env belongs to, or "" for
+// the main program
+func (e *Environment) File() string {
+	if len(e.imports) == 0 {
+		return ""
+	}
+	return e.imports[len(e.imports)-1]
+}
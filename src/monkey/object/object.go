@@ -4,13 +4,21 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"math"
 	"monkey/ast"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 // BuiltinFunction is the type defintion of callable Go Func
 type BuiltinFunction func(args ...Object) Object
 
+// PrintFunction is a builtin that writes to the output of the environment
+// it is called from
+type PrintFunction func(out io.Writer, args ...Object) Object
+
 // ObjectType is the type for object
 type ObjectType string
 
@@ -20,6 +28,7 @@ const (
 	ERROROBJ = "ERROR"
 
 	INTEGEROBJ = "INTEGER"
+	FLOATOBJ   = "FLOAT"
 	BOOLEANOBJ = "BOOLEAN"
 	STRINGOBJ  = "STRING"
 
@@ -30,6 +39,11 @@ const (
 
 	ARRAYOBJ = "ARRAY"
 	HASHOBJ  = "HASH"
+
+	MODULEOBJ = "MODULE"
+
+	STRUCTTYPEOBJ = "STRUCT_TYPE"
+	STRUCTOBJ     = "STRUCT"
 )
 
 // Object provides the object functions
@@ -67,6 +81,16 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey returns the float HashKey
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	if value == 0 {
+		// -0.0 equals 0.0, so both need the bits of 0.0
+		value = 0
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
 // HashKey returns the string HashKey
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
@@ -90,6 +114,25 @@ func (i *Integer) Type() ObjectType {
 	return INTEGEROBJ
 }
 
+// Float is for floating point data type
+type Float struct {
+	Value float64
+}
+
+// Inspect provides the float value repr
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".eIN") {
+		str += ".0"
+	}
+	return str
+}
+
+// Type returns the object type
+func (f *Float) Type() ObjectType {
+	return FLOATOBJ
+}
+
 // Boolean is for bool object type
 type Boolean struct {
 	Value bool
@@ -133,9 +176,19 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Kinds of Error
+const (
+	RUNTIMEERROR  = "RuntimeError"
+	INTERNALERROR = "InternalError"
+	THROWNERROR   = "Error"
+)
+
 // Error represents error
 type Error struct {
 	Message string
+	Kind    string   // one of the error kinds, or a kind given to throw
+	Stack   []string // calls the error propagated through, innermost first
+	Value   Object   // the object given to throw, nil for runtime errors
 }
 
 // Type returns error type
@@ -150,7 +203,8 @@ func (e *Error) Inspect() string {
 
 // Function type for func
 type Function struct {
-	Parameters []*ast.Identifier
+	Name       string // empty for anonymous functions
+	Parameters []ast.Expression
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -170,6 +224,9 @@ func (f *Function) Inspect() string {
 	}
 
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -196,7 +253,8 @@ func (s *String) Inspect() string {
 
 // Builtin is the wrapper for Builtin Functions
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn    BuiltinFunction
+	Print PrintFunction // used instead of Fn when set
 }
 
 // Type returns the Builtin Type
@@ -212,6 +270,7 @@ func (b *Builtin) Inspect() string {
 // Array is the wrapper for array
 type Array struct {
 	Elements []Object
+	Frozen   bool
 }
 
 // Type returns the array type
@@ -243,7 +302,8 @@ type HashPair struct {
 
 // Hash defines the wrapper for hash
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	Frozen bool
 }
 
 // Type for hash object
@@ -267,3 +327,90 @@ func (h *Hash) Inspect() string {
 
 	return out.String()
 }
+
+// SortedPairs returns the pairs of h ordered by the Inspect of their keys,
+// so the result does not depend on map iteration order
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+
+	return pairs
+}
+
+// Module is the namespace created by importing a source file
+type Module struct {
+	Path    string
+	Members *Hash
+}
+
+// Type returns the module type
+func (m *Module) Type() ObjectType {
+	return MODULEOBJ
+}
+
+// Inspect returns the module repr
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("<module %q>", m.Path)
+}
+
+// StructType is the type created by a struct declaration
+type StructType struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function
+}
+
+// Type returns the struct type type
+func (st *StructType) Type() ObjectType {
+	return STRUCTTYPEOBJ
+}
+
+// Inspect returns the struct type repr
+func (st *StructType) Inspect() string {
+	return fmt.Sprintf("<struct %s>", st.Name)
+}
+
+// HasField reports whether name is one of the declared fields
+func (st *StructType) HasField(name string) bool {
+	for _, field := range st.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Struct is an instance of a struct type
+type Struct struct {
+	StructType *StructType
+	Fields     map[string]Object
+	Frozen     bool
+}
+
+// Type returns the struct type
+func (s *Struct) Type() ObjectType {
+	return STRUCTOBJ
+}
+
+// Inspect returns the struct repr, listing fields in declaration order
+func (s *Struct) Inspect() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, name := range s.StructType.Fields {
+		fields = append(fields, fmt.Sprintf("%s: %s", name, s.Fields[name].Inspect()))
+	}
+
+	out.WriteString(s.StructType.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -21,3 +24,51 @@ func TestStringHashKey(t *testing.T) {
 	}
 
 }
+
+func TestFloatHashKey(t *testing.T) {
+	zero := &Float{Value: 0}
+	negativeZero := &Float{Value: math.Copysign(0, -1)}
+	half := &Float{Value: 0.5}
+
+	if zero.HashKey() != negativeZero.HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+
+	if zero.HashKey() == half.HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+}
+
+func TestEnvironmentConstants(t *testing.T) {
+	outer := NewEnvironment()
+	inner := NewEnclosedEnvironment(outer)
+	one := &Integer{Value: 1}
+	two := &Integer{Value: 2}
+
+	if !outer.Define("x", one, true) {
+		t.Fatalf("could not define constant x")
+	}
+	if !outer.IsConst("x") || inner.IsConst("x") {
+		t.Errorf("IsConst should only report constants bound directly in env")
+	}
+	if outer.Define("x", two, false) {
+		t.Errorf("constant x was redefined")
+	}
+	if found, assigned := inner.Assign("x", two); !found || assigned {
+		t.Errorf("assign to constant x: found=%t, assigned=%t", found, assigned)
+	}
+	if !inner.Define("x", two, false) {
+		t.Errorf("could not shadow constant x in an enclosed env")
+	}
+
+	outer.Set("y", one)
+	if found, assigned := inner.Assign("y", two); !found || !assigned {
+		t.Errorf("assign to y: found=%t, assigned=%t", found, assigned)
+	}
+	if y, _ := outer.Get("y"); y != two {
+		t.Errorf("y was not assigned in the env binding it. got=%v", y)
+	}
+	if found, _ := inner.Assign("z", two); found {
+		t.Errorf("assign found unbound name z")
+	}
+}
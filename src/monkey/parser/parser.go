@@ -12,16 +12,20 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y
+	PIPE        // |>
 	EQUALS      // =
 	LESSGREATER // > or <
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
-	INDEX       // array[INDEX]
+	INDEX       // array[INDEX] or object.member
 )
 
 var precedences = map[token.TokenType]int{
+	token.ASSIGN:   ASSIGN,
+	token.PIPE:     PIPE,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -31,7 +35,9 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
+	token.LBRACE:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type (
@@ -43,6 +49,7 @@ type (
 type Parser struct {
 	l      *lexer.Lexer
 	errors []string
+	tokens []token.Token // the token each of errors was found at
 
 	curToken  token.Token
 	peekToken token.Token
@@ -62,6 +69,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -69,8 +77,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.BAR, p.parseLambdaLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -82,7 +94,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.LBRACE, p.parseStructLiteral)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -118,10 +134,21 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ErrorTokens returns the token each of the errors was found at, in the
+// same order as Errors
+func (p *Parser) ErrorTokens() []token.Token {
+	return p.tokens
+}
+
+func (p *Parser) error(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.tokens = append(p.tokens, tok)
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.error(p.peekToken, msg)
 }
 
 // ParseProgram parses the ast
@@ -141,10 +168,19 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -153,11 +189,19 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
-	}
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -167,7 +211,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil && fn.Name == "" {
+		fn.Name = stmt.Name.Value
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -181,7 +229,123 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	declared := make(map[string]bool)
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		var name *ast.Identifier
+		switch p.curToken.Type {
+		case token.IDENT:
+			name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			stmt.Fields = append(stmt.Fields, name)
+		case token.FUNCTION:
+			method := p.parseStructMethod()
+			if method == nil {
+				return nil
+			}
+			name = method.Name
+			stmt.Methods = append(stmt.Methods, method)
+		default:
+			msg := fmt.Sprintf("expected field or method in struct %s, got %s instead",
+				stmt.Name, p.curToken.Type)
+			p.error(p.curToken, msg)
+			return nil
+		}
+
+		if declared[name.Value] {
+			msg := fmt.Sprintf("duplicate member %s in struct %s", name, stmt.Name)
+			p.error(name.Token, msg)
+			return nil
+		}
+		declared[name.Value] = true
+
+		if p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+
+	p.nextToken()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseStructMethod() *ast.StructMethod {
+	if !p.peekTokenIs(token.IDENT) {
+		p.peekError(token.IDENT)
+		return nil
+	}
+
+	stmt := p.parseFunctionStatement()
+	if stmt == nil {
+		return nil
+	}
+
+	return &ast.StructMethod{Name: stmt.Name, Function: stmt.Function}
+}
+
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	stmt := &ast.FunctionStatement{Token: p.curToken}
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	lit.Name = stmt.Name.Value
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters(token.RPAREN)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+	stmt.Function = lit
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -212,7 +376,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.error(p.curToken, msg)
 		return nil
 	}
 
@@ -224,6 +388,29 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = []ast.Expression{p.parseStringLiteral()}
+
+	for {
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if p.peekTokenIs(token.TEMPLATE_MIDDLE) {
+			p.nextToken()
+			str.Parts = append(str.Parts, p.parseStringLiteral())
+			continue
+		}
+
+		if !p.expectPeek(token.TEMPLATE_TAIL) {
+			return nil
+		}
+		str.Parts = append(str.Parts, p.parseStringLiteral())
+
+		return str
+	}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{
 		Token: p.curToken,
@@ -256,6 +443,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 
 	return block
 }
@@ -283,6 +471,15 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			expression.Alternative = p.parseElseIf()
+			if expression.Alternative == nil {
+				return nil
+			}
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -293,31 +490,83 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseElseIf wraps the if expression following `else` in a block, so
+// `else if` chains need no extra braces
+func (p *Parser) parseElseIf() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+	ifExpression := p.parseIfExpression()
+	if ifExpression == nil {
+		return nil
 	}
 
-	p.nextToken()
+	block.Statements = []ast.Statement{
+		&ast.ExpressionStatement{Token: block.Token, Expression: ifExpression},
+	}
+
+	return block
+}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+func (p *Parser) parseFunctionParameters(end token.TokenType) []ast.Expression {
+	parameters := []ast.Expression{}
 
-	for p.peekTokenIs(token.COMMA) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
+		return parameters
+	}
+
+	defaulted := false
+	for {
+		p.nextToken()
+		tok := p.curToken
+		param := p.parseParameter()
+		if _, ok := param.(*ast.DefaultPattern); ok {
+			defaulted = true
+		} else if defaulted && param != nil {
+			p.error(tok, "parameter without a default cannot follow one with a default")
+		}
+		parameters = append(parameters, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return parameters
+}
+
+// parseParameter parses a function parameter: an identifier or a
+// destructuring pattern, either with an optional default value
+func (p *Parser) parseParameter() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT, token.LBRACKET, token.LBRACE:
+		return p.parseDefaultPattern(p.parsePattern())
+	default:
+		msg := fmt.Sprintf("expected parameter name or pattern, got %s instead", p.curToken.Type)
+		p.error(p.curToken, msg)
 		return nil
 	}
+}
+
+// parseDefaultPattern wraps pattern in an ast.DefaultPattern when it is
+// followed by `= default`
+func (p *Parser) parseDefaultPattern(pattern ast.Expression) ast.Expression {
+	if pattern == nil || !p.peekTokenIs(token.ASSIGN) {
+		return pattern
+	}
 
-	return identifiers
+	p.nextToken()
+	exp := &ast.DefaultPattern{Token: p.curToken, Pattern: pattern}
+
+	p.nextToken()
+	exp.Default = p.parseExpression(LOWEST)
+
+	return exp
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
@@ -327,7 +576,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters = p.parseFunctionParameters(token.RPAREN)
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -338,6 +587,34 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+// parseLambdaLiteral parses the shorthand `|x, y| x + y`, whose body is a
+// single expression unless it is written as a block
+func (p *Parser) parseLambdaLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	lit.Parameters = p.parseFunctionParameters(token.BAR)
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	p.nextToken()
+
+	if p.curTokenIs(token.LBRACE) {
+		lit.Body = p.parseBlockStatement()
+		return lit
+	}
+
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
+
+	lit.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+
+	return lit
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -368,6 +645,48 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseAssignExpression parses `target = value`, which groups to the right
+// so that `a = b = 1` assigns 1 to both
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken, Target: target}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+	default:
+		msg := fmt.Sprintf("cannot assign to %s", target)
+		p.error(exp.Token, msg)
+		return nil
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(ASSIGN - 1)
+
+	return exp
+}
+
+// parsePipeExpression rewrites `x |> f(a)` into the call `f(x, a)`
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	pipe := p.curToken
+	precedence := p.curPrecedence()
+	p.nextToken()
+	right := p.parseExpression(precedence)
+	if right == nil {
+		return nil
+	}
+
+	call, ok := right.(*ast.CallExpression)
+	if !ok || call.Pipe {
+		msg := fmt.Sprintf("expected call after |>, got %s", right)
+		p.error(pipe, msg)
+		return nil
+	}
+
+	call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+	call.Pipe = true
+
+	return call
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -377,10 +696,36 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+func (p *Parser) parseSliceExpression(
+	tok token.Token,
+	left, start ast.Expression,
+) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -405,6 +750,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -418,6 +764,285 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	return hash
 }
 
+func (p *Parser) parseStructLiteral(left ast.Expression) ast.Expression {
+	switch left.(type) {
+	case *ast.Identifier, *ast.MemberExpression:
+	default:
+		msg := fmt.Sprintf("expected struct name before {, got %s", left)
+		p.error(p.curToken, msg)
+		return nil
+	}
+
+	lit := &ast.StructLiteral{Token: p.curToken, Type: left}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		lit.Fields = append(lit.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		lit.Values = append(lit.Values, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return lit
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	exp.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+
+			exp.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		exp.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		exp.Finally = p.parseBlockStatement()
+	}
+
+	if exp.Catch == nil && exp.Finally == nil {
+		p.error(exp.Token, "expected catch or finally after try block")
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			continue
+		}
+
+		_, isBlock := arm.Body.(*ast.BlockStatement)
+		if !isBlock && !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.curToken}
+
+	arm.Pattern = p.parsePattern()
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		arm.Body = p.parseBlockStatement()
+	} else {
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+	}
+
+	return arm
+}
+
+// parsePattern parses the pattern starting at curToken: a literal, an
+// identifier to bind (or `_` to ignore), an array pattern or a hash pattern
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.INT:
+		return p.parseIntegerLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	case token.IDENT:
+		return p.parseIdentifier()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) {
+			p.peekError(token.INT)
+			return nil
+		}
+		return p.parsePrefixExpression()
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		msg := fmt.Sprintf("no pattern parse function for %s found", p.curToken.Type)
+		p.error(p.curToken, msg)
+		return nil
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: "_"}
+			if p.peekTokenIs(token.IDENT) {
+				p.nextToken()
+				pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			}
+			break
+		}
+
+		element := p.parseDefaultPattern(p.parsePattern())
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseDefaultPattern(p.parsePattern())
+		if value == nil {
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	// defer untrace(trace("parsePrefixExpression"))
 	expression := &ast.PrefixExpression{
@@ -446,7 +1071,7 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.error(p.curToken, msg)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -472,20 +1097,22 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns the binding power of t as an infix operator, LOWEST
+// for tokens that are not operators
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 
 	return LOWEST
 }
 
-func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
 
-	return LOWEST
+func (p *Parser) curPrecedence() int {
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
 	"testing"
+	"time"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -16,6 +18,7 @@ func TestLetStatements(t *testing.T) {
 		{"let x = 5;", "x", 5},
 		{"let y = true;", "y", true},
 		{"let foobar = y;", "foobar", "y"},
+		{"let z = 10", "z", 10},
 	}
 
 	for _, tt := range tests {
@@ -41,6 +44,116 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	input := "const limit = 10; const name = \"monkey\""
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Token.Type != token.CONST {
+		t.Errorf("stmt.Token.Type not CONST. got=%q", stmt.Token.Type)
+	}
+	if !testIdentifier(t, stmt.Name, "limit") || !testIntegerLiteral(t, stmt.Value, 10) {
+		return
+	}
+	if stmt.String() != "const limit = 10;" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+
+	errorTests := []string{
+		"const = 1;",
+		"const x;",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 1 + 2", "(x = (y = (1 + 2)))"},
+		{"xs[0] = xs[1] * 2", "((xs[0]) = ((xs[1]) * 2))"},
+		{"p.x = p.y", "((p.x) = (p.y))"},
+		{"x = 1 |> f()", "(x = (1 |> f()))"},
+		{"x = y == z", "(x = (y == z))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []string{
+		"1 = 2",
+		"f() = 2",
+		"x + y = 2",
+		"x =",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestErrorTokens(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"let = 5;", 1, 5},
+		{"let x = 1;\n  1 = 2", 2, 5},
+		{"let x = )", 1, 9},
+		{"try { 1 }", 1, 1},
+		{"1 |> 2", 1, 3},
+		{"fn(x = 1, y) { x }", 1, 11},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.ErrorTokens()) != len(p.Errors()) || len(p.Errors()) == 0 {
+			t.Errorf("%q: wrong number of error tokens. errors=%d, tokens=%d",
+				tt.input, len(p.Errors()), len(p.ErrorTokens()))
+			continue
+		}
+		tok := p.ErrorTokens()[0]
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Errorf("%q: wrong position for %q. expected=%d:%d, got=%d:%d", tt.input,
+				p.Errors()[0], tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
 func TestReturnSatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -49,6 +162,7 @@ func TestReturnSatements(t *testing.T) {
 		{"return 5;", 5},
 		{"return true;", true},
 		{"return foobar;", "foobar"},
+		{"return 10", 10},
 	}
 
 	for _, tt := range tests {
@@ -79,6 +193,43 @@ func TestReturnSatements(t *testing.T) {
 	}
 }
 
+func TestStatementsWithoutSemicolons(t *testing.T) {
+	// these used to skip tokens until a semicolon and never return at the
+	// end of input
+	tests := []struct {
+		input              string
+		expectedStatements int
+	}{
+		{"let x = 5", 1},
+		{"return 5", 1},
+		{"const x = 5", 1},
+		{"throw 5", 1},
+		{"let x = 5\nlet y = x\nreturn y", 3},
+		{"let f = fn() { return 1 }", 1},
+		{"if (true) { let x = 1 }", 1},
+	}
+
+	for _, tt := range tests {
+		done := make(chan *ast.Program, 1)
+		var p *Parser
+		go func() {
+			p = New(lexer.New(tt.input))
+			done <- p.ParseProgram()
+		}()
+
+		select {
+		case program := <-done:
+			checkParserErrors(t, p)
+			if len(program.Statements) != tt.expectedStatements {
+				t.Errorf("%q: wrong number of statements. expected=%d, got=%d",
+					tt.input, tt.expectedStatements, len(program.Statements))
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q: parsing did not terminate", tt.input)
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -343,6 +494,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a + b |> f(c == d)",
+			"((a + b) |> f((c == d)))",
+		},
+		{
+			"xs |> map(f) |> sum()",
+			"((xs |> map(f)) |> sum())",
+		},
+		{
+			"x |> obj.method(1) |> g(h(y))",
+			"((x |> (obj.method)(1)) |> g(h(y)))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -495,6 +658,59 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("alternative is not 1 statement. got=%d", len(exp.Alternative.Statements))
+	}
+
+	alternative := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	nested, ok := alternative.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression. got=%T", alternative.Expression)
+	}
+
+	if !testInfixExpression(t, nested.Condition, "x", ">", "y") {
+		return
+	}
+	if nested.Alternative == nil {
+		t.Fatalf("nested.Alternative was nil")
+	}
+
+	if exp.String() != "if(x < y) xelse if(x > y) yelse z" {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+
+	errorTests := []string{
+		"if (x) { 1 } else if { 2 }",
+		"if (x) { 1 } else if (y) 2",
+		"if (x) { 1 } else 2",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -536,6 +752,139 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestLambdaLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expected       string
+	}{
+		{"|x| x * 2", []string{"x"}, "|x| (x * 2)"},
+		{"|| 1", []string{}, "|| 1"},
+		{"|a, b| { let c = a + b; c }", []string{"a", "b"}, "|a, b| let c = (a + b);c"},
+		{"|x| |y| x + y", []string{"x"}, "|x| |y| (x + y)"},
+		{"|[a, b], c = 1| a", []string{"[a, b]", "c = 1"}, "|[a, b], c = 1| a"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, param := range tt.expectedParams {
+			if function.Parameters[i].String() != param {
+				t.Errorf("parameter %d wrong. want %q, got=%q", i, param, function.Parameters[i])
+			}
+		}
+
+		if function.String() != tt.expected {
+			t.Errorf("function.String() wrong. expected=%q, got=%q", tt.expected, function.String())
+		}
+	}
+
+	l := lexer.New("map(xs, |x| x + 1)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if len(call.Arguments) != 2 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
+	}
+	function, ok := call.Arguments[1].(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("call.Arguments[1] is not ast.FunctionLiteral. got=%T", call.Arguments[1])
+	}
+	bodyStmt := function.Body.Statements[0].(*ast.ExpressionStatement)
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", 1)
+
+	errorTests := []string{
+		"|x x",
+		"|x|",
+		"|1| x",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestFunctionStatementParsing(t *testing.T) {
+	input := `fn add(x, y = 1) { x + y }; fn(z) { z }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "add") {
+		return
+	}
+	if stmt.Function.Name != "add" {
+		t.Errorf("stmt.Function.Name is not %q. got=%q", "add", stmt.Function.Name)
+	}
+	if len(stmt.Function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(stmt.Function.Parameters))
+	}
+	if stmt.String() != "fn add(x, y = 1) (x + y)" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+
+	exp := program.Statements[1].(*ast.ExpressionStatement)
+	function, ok := exp.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("exp.Expression is not ast.FunctionLiteral. got=%T", exp.Expression)
+	}
+	if function.Name != "" {
+		t.Errorf("anonymous function has name %q", function.Name)
+	}
+
+	program = New(lexer.New("let f = fn(x) { x }; let g = |x| x;")).ParseProgram()
+	for i, name := range []string{"f", "g"} {
+		let := program.Statements[i].(*ast.LetStatement)
+		if let.Value.(*ast.FunctionLiteral).Name != name {
+			t.Errorf("let did not name function %q. got=%q", name, let.Value.(*ast.FunctionLiteral).Name)
+		}
+	}
+
+	errorTests := []string{
+		"fn add { 1 }",
+		"fn add(x) 1",
+		"fn add(1) { 1 }",
+		"fn add(x = 1, y) { x + y }",
+		"let f = |x = 1, [y, z]| x",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -566,6 +915,71 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDestructuringParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = xs;", "let [a, b] = xs;"},
+		{"let [head, ...tail] = xs;", "let [head, ...tail] = xs;"},
+		{`let {"name": n, "age": a = 0} = h;`, "let {name:n, age:a = 0} = h;"},
+		{"let [x, [y, z = 3]] = xs;", "let [x, [y, z = 3]] = xs;"},
+		{"const [a, b] = xs;", "const [a, b] = xs;"},
+		{`const {"k": k} = h;`, "const {k:k} = h;"},
+		{"fn([a, b], c) { a };", "fn([a, b], c)a"},
+		{`fn({"x": x}, y = x + 1) { y };`, "fn({x:x}, y = (x + 1))y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	l := lexer.New("let [a, b = 2] = xs;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	if stmt.Name != nil {
+		t.Errorf("stmt.Name is not nil. got=%s", stmt.Name)
+	}
+	pattern, ok := stmt.Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("stmt.Pattern is not ast.ArrayPattern. got=%T", stmt.Pattern)
+	}
+	if len(pattern.Elements) != 2 || !testIdentifier(t, pattern.Elements[0], "a") {
+		return
+	}
+	def, ok := pattern.Elements[1].(*ast.DefaultPattern)
+	if !ok {
+		t.Fatalf("pattern.Elements[1] is not ast.DefaultPattern. got=%T", pattern.Elements[1])
+	}
+	if !testIdentifier(t, def.Pattern, "b") || !testIntegerLiteral(t, def.Default, 2) {
+		return
+	}
+
+	errorTests := []string{
+		"let [a, b = ] = xs;",
+		"let [1 + 2] = xs;",
+		"fn(1) { 1 };",
+		"fn(a +) { a };",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5);`
 
@@ -601,6 +1015,54 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestPipeExpressionParsing(t *testing.T) {
+	input := "x |> add(1, 2 * 3)"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if !exp.Pipe {
+		t.Errorf("exp.Pipe is not true")
+	}
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testIdentifier(t, exp.Arguments[0], "x")
+	testLiteralExpression(t, exp.Arguments[1], 1)
+	testInfixExpression(t, exp.Arguments[2], 2, "*", 3)
+
+	if exp.String() != "(x |> add(1, (2 * 3)))" {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+
+	errorTests := []string{
+		"x |> f",
+		"x |> 1 + 2",
+		"x |> f() == y",
+		"x |>",
+		"x | f()",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestCallExpressionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -674,6 +1136,55 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"x=${x + 1}, y=${y}"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("str.Parts does not contain 5 parts. got=%d", len(str.Parts))
+	}
+
+	for i, expected := range []string{"x=", ", y=", ""} {
+		literal, ok := str.Parts[i*2].(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("str.Parts[%d] not *ast.StringLiteral. got=%T", i*2, str.Parts[i*2])
+		}
+		if literal.Value != expected {
+			t.Errorf("literal.Value not %q. got=%q", expected, literal.Value)
+		}
+	}
+
+	testInfixExpression(t, str.Parts[1], "x", "+", 1)
+	testIdentifier(t, str.Parts[3], "y")
+
+	if str.String() != "x=${(x + 1)}, y=${y}" {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+
+	errorTests := []string{
+		`"a${}"`,
+		`"a${x"`,
+		`"a${x y}"`,
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -720,6 +1231,53 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1:2]", "(myArray[1:2])"},
+		{"myArray[:2]", "(myArray[:2])"},
+		{"myArray[1:]", "(myArray[1:])"},
+		{"myArray[:]", "(myArray[:])"},
+		{"myArray[-2:len(myArray) - 1]", "(myArray[(-2):(len(myArray) - 1)])"},
+		{`"hello"[1:3][0]`, "((hello[1:3])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.Expression.String())
+		}
+	}
+
+	l := lexer.New("myArray[1:2]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, sliceExp.Left, "myArray") {
+		return
+	}
+	if !testIntegerLiteral(t, sliceExp.Start, 1) {
+		return
+	}
+	if !testIntegerLiteral(t, sliceExp.End, 2) {
+		return
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -754,6 +1312,12 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 
 		testIntegerLiteral(t, value, expectedValue)
 	}
+
+	for i, key := range []string{"one", "two", "three"} {
+		if hash.Keys[i].String() != key {
+			t.Errorf("hash.Keys[%d] wrong. expected=%q, got=%q", i, key, hash.Keys[i])
+		}
+	}
 }
 
 func TestParsingEmptyHashLiteral(t *testing.T) {
@@ -894,6 +1458,311 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestStructStatement(t *testing.T) {
+	input := `struct Point {
+	x, y
+	fn norm() { self.x * self.x + self.y * self.y }
+	fn scale(k) { Point { x: self.x * k, y: self.y * k } }
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.StructStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Name, "Point") {
+		return
+	}
+	if len(stmt.Fields) != 2 || !testIdentifier(t, stmt.Fields[0], "x") || !testIdentifier(t, stmt.Fields[1], "y") {
+		t.Fatalf("stmt.Fields wrong. got=%v", stmt.Fields)
+	}
+	if len(stmt.Methods) != 2 {
+		t.Fatalf("stmt.Methods does not contain 2 methods. got=%d", len(stmt.Methods))
+	}
+
+	expected := "struct Point {x, y, " +
+		"fn norm() (((self.x) * (self.x)) + ((self.y) * (self.y))), " +
+		"fn scale(k) Point{x: ((self.x) * k), y: ((self.y) * k)}}"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. expected=%q, got=%q", expected, stmt.String())
+	}
+
+	p = New(lexer.New("struct P { x }; struct Q { y };\nP { x: 1 }"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 3 {
+		t.Fatalf("struct statements followed by ; parsed to %d statements, want 3: %q",
+			len(program.Statements), program.String())
+	}
+
+	errorTests := []string{
+		"struct { x }",
+		"struct P { 1 }",
+		"struct P { x, x }",
+		"struct P { x fn x() { 1 } }",
+		"struct P { fn () { 1 } }",
+		"struct P { x",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestStructLiteralAndMemberParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Point { x: 1, y: 2 }", "Point{x: 1, y: 2}"},
+		{"Point {}", "Point{}"},
+		{"Point { x: a + b, }", "Point{x: (a + b)}"},
+		{"p.x", "(p.x)"},
+		{"p.x.y", "((p.x).y)"},
+		{"-p.x * 2", "((-(p.x)) * 2)"},
+		{"p.norm()", "(p.norm)()"},
+		{"a[0].x", "((a[0]).x)"},
+		{"Point { x: 1 }.x", "(Point{x: 1}.x)"},
+		{"geo.Point { x: 1 }", "(geo.Point){x: 1}"},
+		{"a.b.Point {}", "((a.b).Point){}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []string{
+		"p.",
+		"p.1",
+		"1 { x: 1 }",
+		"p[0] { x: 1 }",
+		"f() { x: 1 }",
+		"Point { 1: 2 }",
+		"Point { x 1 }",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestImportExpression(t *testing.T) {
+	input := `let m = import "lib/math";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	exp, ok := stmt.Value.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.ImportExpression. got=%T", stmt.Value)
+	}
+
+	if exp.Path.Value != "lib/math" {
+		t.Errorf("exp.Path.Value not %q. got=%q", "lib/math", exp.Path.Value)
+	}
+
+	if program.String() != `let m = import "lib/math";` {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	p = New(lexer.New("import math"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected parser error for import without a string path")
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	input := `throw "boom"; throw {"message": x}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if stmt.TokenLiteral() != "throw" {
+		t.Errorf("stmt.TokenLiteral not 'throw', got %q", stmt.TokenLiteral())
+	}
+	str, ok := stmt.Value.(*ast.StringLiteral)
+	if !ok || str.Value != "boom" {
+		t.Errorf("stmt.Value is not StringLiteral \"boom\". got=%T(%s)", stmt.Value, stmt.Value)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectParam   string
+		expectCatch   bool
+		expectFinally bool
+	}{
+		{"try { x } catch (e) { y }", "try x catch (e) y", "e", true, false},
+		{"try { x } catch { y }", "try x catch y", "", true, false},
+		{"try { x } finally { z }", "try x finally z", "", false, true},
+		{"try { x } catch (err) { y } finally { z }", "try x catch (err) y finally z", "err", true, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() wrong. expected=%q, got=%q", tt.expected, exp.String())
+		}
+		if tt.expectParam == "" && exp.Param != nil {
+			t.Errorf("exp.Param should be nil. got=%s", exp.Param)
+		}
+		if tt.expectParam != "" && !testIdentifier(t, exp.Param, tt.expectParam) {
+			return
+		}
+		if (exp.Catch != nil) != tt.expectCatch {
+			t.Errorf("exp.Catch presence wrong. got=%v", exp.Catch)
+		}
+		if (exp.Finally != nil) != tt.expectFinally {
+			t.Errorf("exp.Finally presence wrong. got=%v", exp.Finally)
+		}
+	}
+
+	p := New(lexer.New("try { x }"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "expected catch or finally after try block" {
+		t.Errorf("wrong parser errors for try without handlers. got=%v", p.Errors())
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`match (x) { 1 => "one", -1 => "minus one", _ => "other" }`,
+			`match (x) {1 => one, (-1) => minus one, _ => other}`,
+		},
+		{
+			`match (x) { n if n > 10 => n * 2, true => 1, }`,
+			`match (x) {n if (n > 10) => (n * 2), true => 1}`,
+		},
+		{
+			`match (xs) { [] => 0, [a, b] => a + b, [head, ...tail] => head, [_, ...] => 1 }`,
+			`match (xs) {[] => 0, [a, b] => (a + b), [head, ...tail] => head, [_, ..._] => 1}`,
+		},
+		{
+			`match (h) { {"name": n, "tags": [first, ...]} => n, _ => { let x = 1; x } }`,
+			`match (h) {{name:n, tags:[first, ..._]} => n, _ => let x = 1;x}`,
+		},
+		{
+			`match (x) { 1 => { "a" } 2 => { "b" } }`,
+			`match (x) {1 => a, 2 => b}`,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.MatchExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() wrong. expected=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+
+	l := lexer.New(`match (x) { [a, ...rest] if a > 0 => a }`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if !testIdentifier(t, exp.Subject, "x") {
+		return
+	}
+	if len(exp.Arms) != 1 {
+		t.Fatalf("exp.Arms does not contain 1 arm. got=%d", len(exp.Arms))
+	}
+
+	arm := exp.Arms[0]
+	pattern, ok := arm.Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("arm.Pattern is not ast.ArrayPattern. got=%T", arm.Pattern)
+	}
+	if len(pattern.Elements) != 1 || !testIdentifier(t, pattern.Elements[0], "a") {
+		return
+	}
+	if !testIdentifier(t, pattern.Rest, "rest") {
+		return
+	}
+	if !testInfixExpression(t, arm.Guard, "a", ">", 0) {
+		return
+	}
+	if !testIdentifier(t, arm.Body.(ast.Expression), "a") {
+		return
+	}
+
+	errorTests := []string{
+		`match (x) { 1 => 2 3 => 4 }`,
+		`match (x) { a + b => 1 }`,
+		`match (x) { [a b] => 1 }`,
+		`match x { _ => 1 }`,
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -0,0 +1,256 @@
+// Package pretty formats values and source for display in a terminal:
+// collections that do not fit the width are spread over indented lines,
+// strings are quoted, and values and tokens can be colored by their type.
+package pretty
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DEFAULTWIDTH is the width collections are spread over lines beyond
+const DEFAULTWIDTH = 80
+
+// INDENT is the indentation of each nesting level
+const INDENT = "  "
+
+// ANSI escape sequences used for colors
+const (
+	RESET   = "\x1b[0m"
+	RED     = "\x1b[31m"
+	GREEN   = "\x1b[32m"
+	YELLOW  = "\x1b[33m"
+	BLUE    = "\x1b[34m"
+	MAGENTA = "\x1b[35m"
+	CYAN    = "\x1b[36m"
+	GRAY    = "\x1b[90m"
+)
+
+var objectColors = map[object.ObjectType]string{
+	object.INTEGEROBJ:    CYAN,
+	object.FLOATOBJ:      CYAN,
+	object.STRINGOBJ:     GREEN,
+	object.BOOLEANOBJ:    YELLOW,
+	object.NULLOBJ:       GRAY,
+	object.ERROROBJ:      RED,
+	object.FUNCTIONOBJ:   BLUE,
+	object.BUILTINOBJ:    BLUE,
+	object.MODULEOBJ:     MAGENTA,
+	object.STRUCTTYPEOBJ: MAGENTA,
+}
+
+var tokenColors = map[token.TokenType]string{
+	token.INT:             CYAN,
+	token.STRING:          GREEN,
+	token.TEMPLATE_HEAD:   GREEN,
+	token.TEMPLATE_MIDDLE: GREEN,
+	token.TEMPLATE_TAIL:   GREEN,
+	token.TRUE:            YELLOW,
+	token.FALSE:           YELLOW,
+	token.ILLEGAL:         RED,
+	token.COMMENT:         GRAY,
+}
+
+// UseColor reports whether output to a terminal should be colored: it
+// should not when the output is not a terminal, NO_COLOR is set or the
+// terminal is dumb
+func UseColor(terminal bool) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return terminal && os.Getenv("TERM") != "dumb"
+}
+
+// Printer formats values and source
+type Printer struct {
+	Width int
+	Color bool
+}
+
+// New returns a Printer for the default width
+func New(color bool) *Printer {
+	return &Printer{Width: DEFAULTWIDTH, Color: color}
+}
+
+// Format returns the display form of obj
+func (p *Printer) Format(obj object.Object) string {
+	return p.format(obj, "", 0)
+}
+
+// format formats obj starting at column, spreading collections that would
+// pass the width over lines indented one level past indent
+func (p *Printer) format(obj object.Object, indent string, column int) string {
+	if !isCollection(obj) || column+len(flat(obj, false)) <= p.Width {
+		return flat(obj, p.Color)
+	}
+
+	inner := indent + INDENT
+	lines := []string{}
+
+	var open, close string
+	switch obj := obj.(type) {
+	case *object.Array:
+		open, close = "[", "]"
+		for _, e := range obj.Elements {
+			lines = append(lines, p.format(e, inner, len(inner)))
+		}
+	case *object.Hash:
+		open, close = "{", "}"
+		for _, pair := range obj.SortedPairs() {
+			key := flat(pair.Key, false) + ": "
+			value := p.format(pair.Value, inner, len(inner)+len(key))
+			lines = append(lines, flat(pair.Key, p.Color)+": "+value)
+		}
+	case *object.Struct:
+		open, close = obj.StructType.Name+"{", "}"
+		for _, name := range obj.StructType.Fields {
+			value := p.format(obj.Fields[name], inner, len(inner)+len(name)+2)
+			lines = append(lines, name+": "+value)
+		}
+	}
+
+	return open + "\n" + inner + strings.Join(lines, ",\n"+inner) + "\n" + indent + close
+}
+
+func isCollection(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return len(obj.Elements) > 0
+	case *object.Hash:
+		return len(obj.Pairs) > 0
+	case *object.Struct:
+		return len(obj.StructType.Fields) > 0
+	}
+	return false
+}
+
+// Flat returns the display form of obj on one line, without color
+func Flat(obj object.Object) string {
+	return flat(obj, false)
+}
+
+// flat formats obj on one line
+func flat(obj object.Object, color bool) string {
+	elements := []string{}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		for _, e := range obj.Elements {
+			elements = append(elements, flat(e, color))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		for _, pair := range obj.SortedPairs() {
+			elements = append(elements, flat(pair.Key, color)+": "+flat(pair.Value, color))
+		}
+		return "{" + strings.Join(elements, ", ") + "}"
+	case *object.Struct:
+		for _, name := range obj.StructType.Fields {
+			elements = append(elements, name+": "+flat(obj.Fields[name], color))
+		}
+		return obj.StructType.Name + "{" + strings.Join(elements, ", ") + "}"
+	case *object.String:
+		return paint(Quote(obj.Value), GREEN, color)
+	}
+
+	return paint(obj.Inspect(), objectColors[obj.Type()], color)
+}
+
+func paint(s string, color string, enabled bool) string {
+	if !enabled || color == "" {
+		return s
+	}
+	return color + s + RESET
+}
+
+// Quote returns s as a Monkey string literal
+func Quote(s string) string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				out.WriteByte('\\')
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
+
+// Highlight colors the tokens of input, leaving its text unchanged. It
+// returns input as it is when color is off
+func (p *Printer) Highlight(input string) string {
+	if !p.Color {
+		return input
+	}
+
+	lineStarts := []int{0}
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(tok token.Token) int {
+		return lineStarts[tok.Line-1] + tok.Column - 1
+	}
+
+	l := lexer.New(input)
+	tokens := []token.Token{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	tokens = append(tokens, l.Comments()...)
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return offset(tokens[i]) < offset(tokens[j])
+	})
+
+	var out strings.Builder
+	pos := 0
+	for i, tok := range tokens {
+		start, end := offset(tok), len(input)
+		if i+1 < len(tokens) {
+			end = offset(tokens[i+1])
+		}
+		text := strings.TrimRight(input[start:end], " \t\r\n")
+
+		out.WriteString(input[pos:start])
+		out.WriteString(paint(text, tokenColor(tok), true))
+		pos = start + len(text)
+	}
+	out.WriteString(input[pos:])
+
+	return out.String()
+}
+
+func tokenColor(tok token.Token) string {
+	if tok.Type == token.ILLEGAL && strings.HasPrefix(tok.Literal, `"`) {
+		return GREEN // a string still being typed
+	}
+	if color, ok := tokenColors[tok.Type]; ok {
+		return color
+	}
+	if tok.Type == token.IDENT || token.LookupIdent(tok.Literal) == token.IDENT {
+		return ""
+	}
+	return MAGENTA
+}
@@ -0,0 +1,108 @@
+package pretty
+
+import (
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"testing"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{`1`, 80, "1"},
+		{`"1"`, 80, `"1"`},
+		{`"say \"hi\"\n\${x}"`, 80, `"say \"hi\"\n\${x}"`},
+		{`[1, "a", true, [], {}]`, 80, `[1, "a", true, [], {}]`},
+		{`{"b": 2, "a": 1}`, 80, `{"a": 1, "b": 2}`},
+		{`[1, 2, 3]`, 8, "[\n  1,\n  2,\n  3\n]"},
+		{`[[1, 2], [3, 4]]`, 10, "[\n  [1, 2],\n  [3, 4]\n]"},
+		{`{"key": [1, 2, 3]}`, 12, "{\n  \"key\": [\n    1,\n    2,\n    3\n  ]\n}"},
+		{`struct P { x, y } P{x: [1, 2], y: "long"}`, 16,
+			"P{\n  x: [1, 2],\n  y: \"long\"\n}"},
+	}
+
+	for _, tt := range tests {
+		p := &Printer{Width: tt.width}
+
+		got := p.Format(testEval(t, tt.input))
+		if got != tt.expected {
+			t.Errorf("wrong format of %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFormatColor(t *testing.T) {
+	p := New(true)
+
+	got := p.Format(testEval(t, `[1, "a", true, if (false) { 1 }]`))
+	expected := "[" + CYAN + "1" + RESET + ", " + GREEN + `"a"` + RESET + ", " +
+		YELLOW + "true" + RESET + ", " + GRAY + "null" + RESET + "]"
+	if got != expected {
+		t.Errorf("wrong colors.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	p.Width = 6
+	got = p.Format(testEval(t, `[100, 200]`))
+	expected = "[\n  " + CYAN + "100" + RESET + ",\n  " + CYAN + "200" + RESET + "\n]"
+	if got != expected {
+		t.Errorf("width counted colors.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;", MAGENTA + "let" + RESET + " x = " + CYAN + "5" + RESET + ";"},
+		{`puts("a ${b}!")  `, `puts(` + GREEN + `"a ${` + RESET + `b` + GREEN + `}!"` + RESET + `)  `},
+		{"if (true) {\n  x\n}", MAGENTA + "if" + RESET + " (" + YELLOW + "true" + RESET + ") {\n  x\n}"},
+		{`"open`, GREEN + `"open` + RESET},
+		{"x // note\n1", "x " + GRAY + "// note" + RESET + "\n" + CYAN + "1" + RESET},
+	}
+
+	for _, tt := range tests {
+		got := New(true).Highlight(tt.input)
+		if got != tt.expected {
+			t.Errorf("wrong highlight of %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	if got := New(false).Highlight("let x"); got != "let x" {
+		t.Errorf("highlighted without color. got=%q", got)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	defer os.Unsetenv("NO_COLOR")
+	os.Setenv("TERM", "xterm")
+
+	if UseColor(false) {
+		t.Errorf("color used when not a terminal")
+	}
+	if !UseColor(true) {
+		t.Errorf("color not used on a terminal")
+	}
+
+	os.Setenv("NO_COLOR", "")
+	if UseColor(true) {
+		t.Errorf("color used with NO_COLOR set")
+	}
+}
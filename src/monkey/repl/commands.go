@@ -0,0 +1,201 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/token"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// COMMANDHELP lists the colon-commands understood by the REPL
+const COMMANDHELP = `:tokens <input>  print the tokens of input
+:ast <input>     print the syntax tree of input
+:env             list the bindings of the session
+:type <expr>     print the type of the value of expr
+:time <expr>     evaluate expr and print how long it took
+:load <file>     evaluate a file in the session
+:save <file>     write the inputs of the session to a file
+:reset           start over with an empty environment
+:cancel          discard pending multi-line input
+:help            print this help
+`
+
+// FILESDISABLED is printed for :load and :save in sessions that may not use
+// the file system
+const FILESDISABLED = "file commands are disabled in this session"
+
+// runCommand runs a line starting with ':'
+func (s *session) runCommand(line string) {
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch name {
+	case ":tokens":
+		s.printTokens(arg)
+	case ":ast":
+		if program, ok := s.parse(arg); ok {
+			dumpNode(s.out, reflect.ValueOf(program), "")
+		}
+	case ":env":
+		defer s.lock()()
+		for _, name := range s.env.Names() {
+			value, _ := s.env.Get(name)
+			fmt.Fprintf(s.out, "%s: %s\n", name, value.Type())
+		}
+	case ":type":
+		defer s.lock()()
+		if result, ok := s.evalQuiet(arg); ok && result != nil {
+			fmt.Fprintln(s.out, result.Type())
+		} else if ok {
+			fmt.Fprintln(s.out, "no value")
+		}
+	case ":time":
+		defer s.lock()()
+		start := time.Now()
+		if result, ok := s.evalQuiet(arg); ok {
+			s.print(result)
+		}
+		fmt.Fprintf(s.out, "took %s\n", time.Since(start))
+	case ":load":
+		if !s.allowFiles {
+			fmt.Fprintln(s.out, FILESDISABLED)
+			return
+		}
+		source, err := ioutil.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintf(s.out, "could not load %s: %s\n", arg, err)
+			return
+		}
+		s.eval(string(source))
+	case ":save":
+		if !s.allowFiles {
+			fmt.Fprintln(s.out, FILESDISABLED)
+			return
+		}
+		transcript := strings.Join(s.transcript, "\n")
+		if transcript != "" {
+			transcript += "\n"
+		}
+		if err := ioutil.WriteFile(arg, []byte(transcript), 0644); err != nil {
+			fmt.Fprintf(s.out, "could not save %s: %s\n", arg, err)
+		}
+	case ":reset":
+		s.reset()
+	case ":help":
+		io.WriteString(s.out, COMMANDHELP)
+	case CANCEL:
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, try :help\n", name)
+	}
+}
+
+// evalQuiet evaluates input like eval without printing the result. A
+// shared environment must be locked around it
+func (s *session) evalQuiet(input string) (object.Object, bool) {
+	program, ok := s.parse(input)
+	if !ok {
+		return nil, false
+	}
+	s.transcript = append(s.transcript, input)
+
+	return s.run(program), true
+}
+
+func (s *session) printTokens(input string) {
+	l := lexer.New(input)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(s.out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// dumpNode prints the syntax tree rooted at v, one field per line, with
+// nested nodes indented below the field holding them
+func dumpNode(out io.Writer, v reflect.Value, indent string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			fmt.Fprintln(out, "nil")
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		fmt.Fprintf(out, "%#v\n", v.Interface())
+		return
+	}
+
+	fmt.Fprintln(out, v.Type().Name())
+	indent += "  "
+
+	for i := 0; i < v.NumField(); i++ {
+		field, name := v.Field(i), v.Type().Field(i).Name
+		if field.Type() == reflect.TypeOf(token.Token{}) || isEmpty(field) {
+			continue
+		}
+		if v.Type() == reflect.TypeOf(ast.HashLiteral{}) && name == "Keys" {
+			// Pairs is printed in the order of Keys
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Slice:
+			fmt.Fprintf(out, "%s%s:\n", indent, name)
+			for j := 0; j < field.Len(); j++ {
+				fmt.Fprintf(out, "%s  - ", indent)
+				dumpNode(out, field.Index(j), indent+"    ")
+			}
+		case reflect.Map:
+			fmt.Fprintf(out, "%s%s:\n", indent, name)
+			for _, key := range mapKeys(v, field) {
+				fmt.Fprintf(out, "%s  - Key: ", indent)
+				dumpNode(out, key, indent+"    ")
+				fmt.Fprintf(out, "%s    Value: ", indent)
+				dumpNode(out, field.MapIndex(key), indent+"    ")
+			}
+		default:
+			fmt.Fprintf(out, "%s%s: ", indent, name)
+			dumpNode(out, field, indent)
+		}
+	}
+}
+
+// mapKeys returns the keys of the map field of node v: in source order for
+// a hash literal, sorted otherwise
+func mapKeys(v, field reflect.Value) []reflect.Value {
+	if v.Type() == reflect.TypeOf(ast.HashLiteral{}) {
+		ordered := v.FieldByName("Keys")
+		keys := make([]reflect.Value, ordered.Len())
+		for i := range keys {
+			keys[i] = ordered.Index(i)
+		}
+		return keys
+	}
+
+	keys := field.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Interface().(ast.Node).String() < keys[j].Interface().(ast.Node).String()
+	})
+	return keys
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,449 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// HISTORYFILE is the file in the user's home directory that keeps the
+// lines entered at the terminal across sessions
+const HISTORYFILE = ".monkey_history"
+
+// HISTORYSIZE is the number of history lines kept
+const HISTORYSIZE = 1000
+
+// errInterrupted is returned by ReadLine when the user presses Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the input of the REPL a line at a time
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// plainReader reads lines without any editing, for input that is not a
+// terminal
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprintf(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// newLineReader returns a line editor when in is a terminal, and a plain
+// reader otherwise. highlight, when not nil, colors the line being edited
+func newLineReader(in io.Reader, out io.Writer, complete func(string) []string, highlight func(string) string) lineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		e := newEditor(f, out, complete)
+		e.highlight = highlight
+		if home, err := os.UserHomeDir(); err == nil {
+			e.loadHistory(filepath.Join(home, HISTORYFILE))
+		}
+		return &terminalReader{editor: e, fd: f.Fd()}
+	}
+
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// terminalReader runs the editor with the terminal in raw mode, restoring
+// it between lines so evaluation output is printed normally
+type terminalReader struct {
+	editor *editor
+	fd     uintptr
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	return r.editor.ReadLine(prompt)
+}
+
+// editor is a single-line terminal editor with cursor movement, history,
+// reverse search and tab completion. It expects its input in raw mode
+type editor struct {
+	in        *bufio.Reader
+	out       io.Writer
+	complete  func(prefix string) []string
+	highlight func(line string) string
+
+	history     []string
+	historyFile string
+
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func newEditor(in io.Reader, out io.Writer, complete func(string) []string) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, complete: complete}
+}
+
+func ctrl(r rune) rune {
+	return r & 0x1f
+}
+
+// ReadLine reads one line, returning io.EOF for Ctrl-D on an empty line
+// and errInterrupted for Ctrl-C
+func (e *editor) ReadLine(prompt string) (string, error) {
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+
+	historyIdx := len(e.history)
+	edited := ""
+
+	e.refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(e.buf) > 0 {
+				return e.accept(), nil
+			}
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			return e.accept(), nil
+		case ctrl('C'):
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case ctrl('A'):
+			e.pos = 0
+		case ctrl('E'):
+			e.pos = len(e.buf)
+		case ctrl('B'):
+			e.move(-1)
+		case ctrl('F'):
+			e.move(1)
+		case ctrl('K'):
+			e.buf = e.buf[:e.pos]
+		case ctrl('U'):
+			e.buf = append(e.buf[:0], e.buf[e.pos:]...)
+			e.pos = 0
+		case ctrl('W'):
+			e.deleteWord()
+		case ctrl('L'):
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'), ctrl('N'):
+			historyIdx, edited = e.recall(historyIdx, edited, r == ctrl('P'))
+		case ctrl('R'):
+			if e.search() {
+				return e.accept(), nil
+			}
+		case '\t':
+			e.completeWord()
+		case 127, ctrl('H'):
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case 27:
+			switch e.readEscape() {
+			case "A":
+				historyIdx, edited = e.recall(historyIdx, edited, true)
+			case "B":
+				historyIdx, edited = e.recall(historyIdx, edited, false)
+			case "C":
+				e.move(1)
+			case "D":
+				e.move(-1)
+			case "H", "1~", "7~":
+				e.pos = 0
+			case "F", "4~", "8~":
+				e.pos = len(e.buf)
+			case "3~":
+				e.deleteAt(e.pos)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+
+		e.refresh()
+	}
+}
+
+// accept ends the line being edited and records it in the history
+func (e *editor) accept() string {
+	io.WriteString(e.out, "\r\n")
+
+	line := string(e.buf)
+	e.addHistory(line)
+	return line
+}
+
+func (e *editor) refresh() {
+	line := string(e.buf)
+	if e.highlight != nil {
+		line = e.highlight(line)
+	}
+
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, line)
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *editor) move(delta int) {
+	e.pos += delta
+	if e.pos < 0 {
+		e.pos = 0
+	}
+	if e.pos > len(e.buf) {
+		e.pos = len(e.buf)
+	}
+}
+
+func (e *editor) insert(runes []rune) {
+	tail := append(append([]rune{}, runes...), e.buf[e.pos:]...)
+	e.buf = append(e.buf[:e.pos], tail...)
+	e.pos += len(runes)
+}
+
+func (e *editor) deleteAt(pos int) {
+	if pos < len(e.buf) {
+		e.buf = append(e.buf[:pos], e.buf[pos+1:]...)
+	}
+}
+
+func (e *editor) deleteWord() {
+	start := e.pos
+	for start > 0 && unicode.IsSpace(e.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(e.buf[start-1]) {
+		start--
+	}
+
+	e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+	e.pos = start
+}
+
+// readEscape reads the rest of an escape sequence such as "\x1b[A",
+// returning what follows the '[' or 'O'
+func (e *editor) readEscape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return ""
+	}
+
+	var seq strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq.WriteRune(r)
+		if r < '0' || r > '9' {
+			return seq.String()
+		}
+	}
+}
+
+// recall replaces the line with the previous or next history entry. The
+// line being edited is kept in edited so moving past the newest entry
+// brings it back
+func (e *editor) recall(idx int, edited string, older bool) (int, string) {
+	if idx == len(e.history) {
+		edited = string(e.buf)
+	}
+
+	if older && idx > 0 {
+		idx--
+	} else if !older && idx < len(e.history) {
+		idx++
+	} else {
+		return idx, edited
+	}
+
+	line := edited
+	if idx < len(e.history) {
+		line = e.history[idx]
+	}
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+
+	return idx, edited
+}
+
+// search runs a reverse incremental search through the history, leaving
+// the match in the line. It reports whether the search ended with Enter,
+// which accepts the line as well
+func (e *editor) search() bool {
+	original := string(e.buf)
+	query := ""
+	idx := e.findHistory(query, len(e.history)-1)
+
+	for {
+		match := ""
+		if idx >= 0 {
+			match = e.history[idx]
+		}
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", query, match)
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false
+		}
+
+		switch {
+		case r == ctrl('R'):
+			if idx > 0 {
+				if older := e.findHistory(query, idx-1); older >= 0 {
+					idx = older
+				}
+			}
+			continue
+		case r == 127 || r == ctrl('H'):
+			if query != "" {
+				query = string([]rune(query)[:len([]rune(query))-1])
+				idx = e.findHistory(query, len(e.history)-1)
+			}
+			continue
+		case r == ctrl('G') || r == ctrl('C'):
+			e.buf = []rune(original)
+			e.pos = len(e.buf)
+			return false
+		case unicode.IsPrint(r):
+			query += string(r)
+			if idx < 0 {
+				idx = len(e.history) - 1
+			}
+			idx = e.findHistory(query, idx)
+			continue
+		}
+
+		if match != "" {
+			e.buf = []rune(match)
+			e.pos = len(e.buf)
+		}
+		if r == 27 {
+			e.readEscape()
+		}
+		return r == '\r' || r == '\n'
+	}
+}
+
+func (e *editor) findHistory(query string, from int) int {
+	for i := from; i >= 0; i-- {
+		if strings.Contains(e.history[i], query) {
+			return i
+		}
+	}
+	return -1
+}
+
+// completeWord completes the identifier before the cursor, printing the
+// candidates when there is no longer common prefix to insert
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start := e.pos
+	for start > 0 && isIdentRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" {
+		return
+	}
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	if len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):]))
+		return
+	}
+
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func isIdentRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_' || '0' <= r && r <= '9'
+}
+
+// loadHistory reads the history from path and appends later lines to it
+func (e *editor) loadHistory(path string) {
+	e.historyFile = path
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+
+	if len(e.history) > HISTORYSIZE {
+		e.history = e.history[len(e.history)-HISTORYSIZE:]
+		ioutil.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+}
+
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > HISTORYSIZE {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
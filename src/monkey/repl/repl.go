@@ -1,18 +1,30 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/pretty"
+	"monkey/token"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // PROMPT prompts the user
 const PROMPT = ">> "
 
+// CONTINUATION prompts for the rest of an incomplete input
+const CONTINUATION = ".. "
+
+// CANCEL, entered at the continuation prompt, discards the pending input
+const CANCEL = ":cancel"
+
 // MONKEYFACE is the monkey logo
 const MONKEYFACE = `            __,__
    .--.  .-"     "-.  .--.
@@ -27,34 +39,211 @@ const MONKEYFACE = `            __,__
            '-----'
 `
 
-// Start is the main point for REPL
+// Start is the main point for REPL. When in is a terminal, lines are read
+// with a line editor that keeps history and completes names
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	run(in, out, newSession(out, true))
+}
+
+// run reads and evaluates the input of session s until in ends
+func run(in io.Reader, out io.Writer, s *session) {
+	reader := newLineReader(in, out, s.complete, s.printer.Highlight)
 
+	pending := ""
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if pending != "" {
+			prompt = CONTINUATION
+		}
+
+		line, err := reader.ReadLine(prompt)
+		if err == errInterrupted {
+			pending = ""
+			continue
+		}
+		if err != nil {
+			if pending != "" {
+				io.WriteString(out, "\n")
+				s.eval(pending)
+			}
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if pending != "" && strings.TrimSpace(line) == CANCEL {
+			pending = ""
+			continue
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+		if pending == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			s.runCommand(strings.TrimSpace(line))
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		input := pending + line
+		if incomplete(input) {
+			pending = input + "\n"
+			continue
+		}
+		pending = ""
+
+		s.eval(input)
+	}
+}
+
+// session holds the state of one REPL run: its environment and the inputs
+// evaluated in it so far. When the environment is shared with other
+// sessions, shared guards it
+type session struct {
+	out        io.Writer
+	printer    *pretty.Printer
+	env        *object.Environment
+	shared     *sync.Mutex
+	transcript []string
+	allowFiles bool // whether :load, :save and import may touch the file system
+}
+
+// newSession returns a session printing to out, in color when out is a
+// terminal that allows it. Unless allowFiles is set, its programs cannot
+// import and it refuses the file commands
+func newSession(out io.Writer, allowFiles bool) *session {
+	terminal := false
+	if f, ok := out.(*os.File); ok {
+		terminal = isTerminal(f.Fd())
+	}
+
+	s := &session{out: out, printer: pretty.New(pretty.UseColor(terminal)), allowFiles: allowFiles}
+	s.reset()
+	return s
+}
+
+func (s *session) reset() {
+	if s.shared != nil {
+		io.WriteString(s.out, "cannot reset a shared environment\n")
+		return
+	}
+
+	s.env = object.NewEnvironment()
+	s.env.SetOutput(s.out)
+	if !s.allowFiles {
+		s.env.DisableImports()
+	}
+	s.transcript = nil
+}
+
+// parse parses input, printing any parse errors
+func (s *session) parse(input string) (*ast.Program, bool) {
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(s.out, p.Errors())
+		return nil, false
+	}
+
+	return program, true
+}
+
+// eval evaluates input in the session environment and prints the result
+func (s *session) eval(input string) {
+	program, ok := s.parse(input)
+	if !ok {
+		return
+	}
+	s.transcript = append(s.transcript, input)
+
+	defer s.lock()()
+	s.print(s.run(program))
+}
+
+// run evaluates program in the session environment, printing to the
+// session. A shared environment must be locked around it and around any use
+// of the result. A panic in the evaluator ends only this input, as an
+// internal error
+func (s *session) run(program *ast.Program) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = &object.Error{
+				Message: fmt.Sprintf("internal error: %v", r),
+				Kind:    object.INTERNALERROR,
+			}
+		}
+	}()
+
+	s.env.SetOutput(s.out)
+	return evaluator.Eval(program, s.env)
+}
+
+// lock locks a shared environment, returning the function that unlocks it
+func (s *session) lock() func() {
+	if s.shared == nil {
+		return func() {}
+	}
+
+	s.shared.Lock()
+	return s.shared.Unlock
+}
+
+// print writes the display form of a result, if there is one
+func (s *session) print(result object.Object) {
+	if result != nil {
+		io.WriteString(s.out, s.printer.Format(result))
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// incomplete reports whether input needs more lines before it can parse:
+// it has unclosed brackets or interpolations, an unterminated string, or
+// ends in an operator that expects an operand
+func incomplete(input string) bool {
+	l := lexer.New(input)
+
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.TEMPLATE_HEAD:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET, token.TEMPLATE_TAIL:
+			depth--
+		case token.ILLEGAL:
+			if strings.HasPrefix(tok.Literal, `"`) || strings.HasPrefix(tok.Literal, "}") {
+				return true
+			}
+		}
+		last = tok
+	}
+
+	if depth > 0 {
+		return true
+	}
+
+	switch last.Type {
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.ASTERISK,
+		token.SLASH, token.LT, token.GT, token.EQ, token.NOT_EQ, token.ARROW,
+		token.PIPE, token.COMMA, token.COLON, token.DOT:
+		return true
+	}
+
+	return false
+}
+
+// complete returns the keywords, builtins and bound names starting with
+// prefix, sorted and without duplicates
+func (s *session) complete(prefix string) []string {
+	names := append(token.Keywords(), evaluator.BuiltinNames()...)
+	unlock := s.lock()
+	names = append(names, s.env.Names()...)
+	unlock()
+	sort.Strings(names)
+
+	candidates := []string{}
+	for i, name := range names {
+		if strings.HasPrefix(name, prefix) && (i == 0 || names[i-1] != name) {
+			candidates = append(candidates, name)
 		}
 	}
+	return candidates
 }
 
 func printParseErrors(out io.Writer, errors []string) {
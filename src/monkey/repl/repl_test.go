@@ -0,0 +1,470 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n x + y\n}", false},
+		{"[1, 2,", true},
+		{"puts(1", true},
+		{"let x = 1 +", true},
+		{"xs |>", true},
+		{`"unterminated`, true},
+		{`"x=${x`, true},
+		{`"x=${x} and`, true},
+		{`"x=${x}"`, false},
+		{`"{"`, false},
+		{"}", false},
+		{"let x = 5; )", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiline(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y
+}
+add(1,
+  2)
+let broken = fn() {
+  1 +
+:cancel
+"still ${add(1, 1)}
+ here"
+puts("end"
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := PROMPT + CONTINUATION + CONTINUATION +
+		PROMPT + CONTINUATION + "3\n" +
+		PROMPT + CONTINUATION + CONTINUATION +
+		PROMPT + CONTINUATION + `"still 2\n here"` + "\n" +
+		PROMPT + CONTINUATION + "\n" + MONKEYFACE
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("wrong output.\nexpected prefix=%q\ngot=%q", expected, out.String())
+	}
+
+	parseError := "\texpected next token to be ), got EOF instead\n"
+	if !strings.HasSuffix(out.String(), parseError) {
+		t.Errorf("input pending at EOF was not parsed. got=%q", out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib.monkey")
+	if err := ioutil.WriteFile(lib, []byte("let double = fn(x) { x * 2 };"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	transcript := filepath.Join(dir, "session.monkey")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":tokens let x", "1:1\tLET\t\"let\"\n1:5\tIDENT\t\"x\"\n1:6\tEOF\t\"\"\n"},
+		{":ast -a", "Program\n" +
+			"  Statements:\n" +
+			"    - ExpressionStatement\n" +
+			"        Expression: PrefixExpression\n" +
+			"          Operator: \"-\"\n" +
+			"          Right: Identifier\n" +
+			"            Value: \"a\"\n"},
+		{":ast {\"b\": 1, \"a\": 2}", "Program\n" +
+			"  Statements:\n" +
+			"    - ExpressionStatement\n" +
+			"        Expression: HashLiteral\n" +
+			"          Pairs:\n" +
+			"            - Key: StringLiteral\n" +
+			"                Value: \"b\"\n" +
+			"              Value: IntegerLiteral\n" +
+			"                Value: 1\n" +
+			"            - Key: StringLiteral\n" +
+			"                Value: \"a\"\n" +
+			"              Value: IntegerLiteral\n" +
+			"                Value: 2\n"},
+		{"let x = 5\nlet s = \"a\"\n:env", "s: STRING\nx: INTEGER\n"},
+		{":type 1 + 1", "INTEGER\n"},
+		{":type let y = 1", "no value\n"},
+		{"let x = 5\n:reset\n:env\nx", "ERROR: identifier not found: x\n"},
+		{":load " + lib + "\ndouble(4)", "8\n"},
+		{":load " + filepath.Join(dir, "missing"), "could not load "},
+		{":nope", "unknown command :nope, try :help\n"},
+		{":help", COMMANDHELP},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		got := strings.Replace(out.String(), PROMPT, "", -1)
+		if !strings.HasPrefix(got, tt.expected) {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	var out bytes.Buffer
+	Start(strings.NewReader(`:ast {"a": 1}`), &out)
+	if strings.Count(out.String(), "StringLiteral") != 1 {
+		t.Errorf(":ast printed a hash pair more than once. got=%q", out.String())
+	}
+
+	out.Reset()
+	Start(strings.NewReader(":time 2 * 3"), &out)
+	if !strings.HasPrefix(out.String(), PROMPT+"6\ntook ") {
+		t.Errorf("wrong :time output. got=%q", out.String())
+	}
+
+	input := "let a = 1\n:type a + 1\nlet b = fn() {\n a\n}\n" + "1 +\n:cancel\n:save " + transcript
+	Start(strings.NewReader(input), &out)
+
+	saved, err := ioutil.ReadFile(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "let a = 1\na + 1\nlet b = fn() {\n a\n}\n"
+	if string(saved) != expected {
+		t.Errorf("wrong transcript.\nexpected=%q\ngot=%q", expected, string(saved))
+	}
+}
+
+func TestEditor(t *testing.T) {
+	complete := func(prefix string) []string {
+		candidates := []string{}
+		for _, name := range []string{"len", "let", "lines", "puts"} {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, name)
+			}
+		}
+		return candidates
+	}
+
+	tests := []struct {
+		input    string
+		history  []string
+		expected string
+	}{
+		{"abc\r", nil, "abc"},
+		{"ac\x02b\r", nil, "abc"},
+		{"bc\x01a\x05d\r", nil, "abcd"},
+		{"abx\x7fc\r", nil, "abc"},
+		{"abc\x1b[D\x1b[D\x0b\r", nil, "a"},
+		{"abc\x1b[D\x15\r", nil, "c"},
+		{"let x = 5\x17\x17y\r", nil, "let x y"},
+		{"abc\x01\x04\x1b[3~\r", nil, "c"},
+		{"\x10\r", []string{"one", "two"}, "two"},
+		{"\x1b[A\x1b[A\r", []string{"one", "two"}, "one"},
+		{"x\x1b[A\x1b[B\r", []string{"one"}, "x"},
+		{"\x12on\r", []string{"one", "two", "three"}, "one"},
+		{"\x12t\x12\r", []string{"one", "two", "three"}, "two"},
+		{"\x12tw\x06!\r", []string{"one", "two"}, "two!"},
+		{"x\x12tw\x07\r", []string{"two"}, "x"},
+		{"pu\t(1)\r", nil, "puts(1)"},
+		{"l\t\r", nil, "l"},
+		{"le\tn\r", nil, "len"},
+		{"zz\t\r", nil, "zz"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := newEditor(strings.NewReader(tt.input), &out, complete)
+		e.history = tt.history
+
+		line, err := e.ReadLine(PROMPT)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. expected=%q, got=%q", tt.input, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorControl(t *testing.T) {
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader("ab\x03\x04"), &out, nil)
+
+	if _, err := e.ReadLine(PROMPT); err != errInterrupted {
+		t.Fatalf("expected interrupt, got %v", err)
+	}
+	if !strings.Contains(out.String(), "^C") {
+		t.Errorf("interrupt not echoed, got %q", out.String())
+	}
+	if _, err := e.ReadLine(PROMPT); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestEditorHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, HISTORYFILE)
+	if err := ioutil.WriteFile(path, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader("one\rone\r\r\x10\x10\r"), &out, nil)
+	e.loadHistory(path)
+
+	for i := 0; i < 3; i++ {
+		if _, err := e.ReadLine(PROMPT); err != nil {
+			t.Fatal(err)
+		}
+	}
+	line, err := e.ReadLine(PROMPT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "old" {
+		t.Errorf("wrong recalled line. expected=%q, got=%q", "old", line)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "old\none\nold\n" {
+		t.Errorf("wrong history file. got=%q", string(data))
+	}
+}
+
+// testClient is a connection to a REPL server that sends one line at a
+// time and reads the output up to the next prompt
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialTest(t *testing.T, l net.Listener) *testClient {
+	conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	c.readPrompt()
+	return c
+}
+
+func (c *testClient) readPrompt() string {
+	var out strings.Builder
+	for !strings.HasSuffix(out.String(), PROMPT) {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			c.t.Fatalf("reading %q: %v", out.String(), err)
+		}
+		out.WriteByte(b)
+	}
+	return strings.TrimSuffix(out.String(), PROMPT)
+}
+
+func (c *testClient) send(line string) string {
+	if _, err := io.WriteString(c.conn, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.readPrompt()
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		shared   bool
+		expected string
+	}{
+		{false, "ERROR: identifier not found: x\n"},
+		{true, "5\n"},
+	}
+
+	for i, tt := range tests {
+		l, err := Listen("unix:" + filepath.Join(dir, fmt.Sprintf("%d.sock", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &Server{Shared: tt.shared}
+		go srv.Serve(l)
+
+		first, second := dialTest(t, l), dialTest(t, l)
+		first.send("let x = 5")
+
+		if got := second.send("x"); got != tt.expected {
+			t.Errorf("shared=%t: wrong value. expected=%q, got=%q", tt.shared, tt.expected, got)
+		}
+		if got := second.send(`puts("second")`); got != "second\nnull\n" {
+			t.Errorf("shared=%t: output went astray. got=%q", tt.shared, got)
+		}
+
+		first.conn.Close()
+		second.conn.Close()
+		l.Close()
+	}
+}
+
+func TestServerSessionFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "secret.monkey")
+	if err := ioutil.WriteFile(file, []byte("let secret = 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, allowFiles := range []bool{false, true} {
+		l, err := Listen("unix:" + filepath.Join(dir, fmt.Sprintf("%t.sock", allowFiles)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &Server{Shared: true, AllowFiles: allowFiles}
+		go srv.Serve(l)
+
+		c := dialTest(t, l)
+		if got := c.send("5 / 0"); !strings.Contains(got, "integer divide by zero") {
+			t.Errorf("panic not reported as an error. got=%q", got)
+		}
+		if got := c.send("1 + 1"); got != "2\n" {
+			t.Errorf("session did not continue after a panic. got=%q", got)
+		}
+		if got := dialTest(t, l).send("2 + 2"); got != "4\n" {
+			t.Errorf("shared environment left locked after a panic. got=%q", got)
+		}
+
+		if got := c.send(":load " + file); !allowFiles && got != FILESDISABLED+"\n" {
+			t.Errorf(":load not refused. got=%q", got)
+		}
+		got := c.send("secret")
+		if allowFiles && got != "1\n" {
+			t.Errorf("allowed :load did not run the file. got=%q", got)
+		}
+		if !allowFiles && got != "ERROR: identifier not found: secret\n" {
+			t.Errorf("refused :load ran the file. got=%q", got)
+		}
+
+		got = c.send(`(import "` + file + `").secret`)
+		if allowFiles && got != "1\n" {
+			t.Errorf("allowed import did not run the file. got=%q", got)
+		}
+		if !allowFiles && !strings.Contains(got, "imports are disabled") {
+			t.Errorf("import not refused. got=%q", got)
+		}
+
+		saved := filepath.Join(dir, fmt.Sprintf("saved-%t.monkey", allowFiles))
+		c.send(":save " + saved)
+		if _, err := os.Stat(saved); (err == nil) != allowFiles {
+			t.Errorf("allowFiles=%t: :save wrote the file: %t", allowFiles, err == nil)
+		}
+
+		c.conn.Close()
+		l.Close()
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		address string
+		local   bool
+	}{
+		{"unix:" + filepath.Join(dir, "local.sock"), true},
+		{"tcp:127.0.0.1:0", true},
+		{"tcp:localhost:0", true},
+		{"tcp::0", false},
+	}
+
+	for _, tt := range tests {
+		l, err := Listen(tt.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if IsLocal(l) != tt.local {
+			t.Errorf("IsLocal(%s) = %t, want %t", tt.address, !tt.local, tt.local)
+		}
+		l.Close()
+	}
+}
+
+func TestServerMaxSessions(t *testing.T) {
+	l, err := Listen("tcp:127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	srv := &Server{MaxSessions: 1}
+	go srv.Serve(l)
+
+	first := dialTest(t, l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused, _ := ioutil.ReadAll(conn)
+	conn.Close()
+	if string(refused) != TOOMANYSESSIONS {
+		t.Errorf("second session not refused. got=%q", string(refused))
+	}
+
+	first.conn.Close()
+	for srv.Sessions() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	dialTest(t, l).conn.Close()
+}
+
+func TestListen(t *testing.T) {
+	for _, address := range []string{"localhost:80", "udp:localhost:80"} {
+		if _, err := Listen(address); err == nil {
+			t.Errorf("expected an error listening on %q", address)
+		}
+	}
+}
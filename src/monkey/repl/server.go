@@ -0,0 +1,124 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/object"
+	"net"
+	"strings"
+	"sync"
+)
+
+// TOOMANYSESSIONS is written to connections refused for the session limit
+const TOOMANYSESSIONS = "too many sessions, try again later\n"
+
+// Listen listens on address, given as unix:/path or tcp:host:port
+func Listen(address string) (net.Listener, error) {
+	i := strings.Index(address, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid address %q, want unix:/path or tcp:host:port", address)
+	}
+
+	switch network := address[:i]; network {
+	case "unix", "tcp":
+		return net.Listen(network, address[i+1:])
+	default:
+		return nil, fmt.Errorf("unknown network %q, want unix or tcp", network)
+	}
+}
+
+// IsLocal reports whether l only accepts connections from this machine,
+// being a unix socket or listening on a loopback address
+func IsLocal(l net.Listener) bool {
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		return addr.IP.IsLoopback()
+	}
+	return true
+}
+
+// Server runs a REPL session for each connection it accepts
+type Server struct {
+	// Shared makes all sessions evaluate in one environment, one input at
+	// a time, instead of each in its own
+	Shared bool
+
+	// MaxSessions limits the sessions running at once, with 0 for no limit
+	MaxSessions int
+
+	// AllowFiles lets sessions use :load, :save and import, which read and
+	// write files as the user running the server
+	AllowFiles bool
+
+	mu       sync.Mutex
+	sessions int
+	env      *object.Environment
+	envLock  sync.Mutex
+}
+
+// Serve accepts connections on l until it is closed, returning the error
+// that ended it
+func (srv *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		if !srv.acquire() {
+			io.WriteString(conn, TOOMANYSESSIONS)
+			conn.Close()
+			continue
+		}
+
+		go func() {
+			defer srv.release()
+			defer conn.Close()
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintf(conn, "internal error: %v\n", r)
+				}
+			}()
+			run(conn, conn, srv.newSession(conn))
+		}()
+	}
+}
+
+// Sessions returns the number of sessions running
+func (srv *Server) Sessions() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.sessions
+}
+
+func (srv *Server) acquire() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.MaxSessions > 0 && srv.sessions >= srv.MaxSessions {
+		return false
+	}
+	srv.sessions++
+	return true
+}
+
+func (srv *Server) release() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.sessions--
+}
+
+func (srv *Server) newSession(out io.Writer) *session {
+	s := newSession(out, srv.AllowFiles)
+	if !srv.Shared {
+		return s
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.env == nil {
+		srv.env = s.env
+	}
+	s.env = srv.env
+	s.shared = &srv.envLock
+	return s
+}
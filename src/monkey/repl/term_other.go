@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package repl
+
+import "errors"
+
+// isTerminal reports whether fd refers to a terminal. Raw mode is only
+// implemented on Linux, so elsewhere the REPL always reads plain lines
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw mode is not supported on this platform")
+}
@@ -1,17 +1,26 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 // TokenTypes
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // recorded by the lexer, never returned as a token
 
 	// Identifiers + literals
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"
 	STRING = "STRING"
 
+	// Interpolated strings, e.g. "a${x}b${y}c" lexes as
+	// TEMPLATE_HEAD(a) x TEMPLATE_MIDDLE(b) y TEMPLATE_TAIL(c)
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -24,11 +33,16 @@ const (
 	GT     = ">"
 	EQ     = "=="
 	NOT_EQ = "!="
+	ARROW  = "=>"
+	PIPE   = "|>"
+	BAR    = "|"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -45,21 +59,39 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	IMPORT   = "IMPORT"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	MATCH    = "MATCH"
+	STRUCT   = "STRUCT"
+	CONST    = "CONST"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line of the first character
+	Column  int // 1-based column of the first character
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"import":  IMPORT,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"match":   MATCH,
+	"struct":  STRUCT,
+	"const":   CONST,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -68,3 +100,13 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// Keywords returns the reserved words of the language in sorted order
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
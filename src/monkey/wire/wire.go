@@ -0,0 +1,74 @@
+// Package wire reads and writes the messages of the Language Server and
+// Debug Adapter protocols: JSON content framed by a Content-Length header
+package wire
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxContentLength is the largest message content Read accepts, so a peer
+// cannot make it allocate without bound
+const MaxContentLength = 64 << 20
+
+// Read reads the content of one message framed by a Content-Length
+// header. Header lines must fit in the buffer of r
+func Read(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		raw, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, fmt.Errorf("header line longer than %d bytes", r.Size())
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line := strings.TrimRight(string(raw), "\r\n")
+		if line == "" {
+			break
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("malformed header %q", line)
+		}
+		if strings.EqualFold(line[:colon], "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[colon+1:]))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", line[colon+1:])
+			}
+			if length > MaxContentLength {
+				return nil, fmt.Errorf("Content-Length %d exceeds the limit of %d", length, MaxContentLength)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Write writes msg as JSON framed by a Content-Length header
+func Write(w io.Writer, msg interface{}) error {
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
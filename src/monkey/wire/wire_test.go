@@ -0,0 +1,65 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	input := "Content-Length: 7\r\nContent-Type: application/json\r\n\r\n{\"a\":1}" +
+		"content-length: 2\n\n[]"
+	r := bufio.NewReader(strings.NewReader(input))
+
+	for _, expected := range []string{`{"a":1}`, "[]"} {
+		content, err := Read(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(content) != expected {
+			t.Errorf("wrong content. expected=%q, got=%q", expected, content)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Type: x\r\n\r\n", "missing Content-Length header"},
+		{"no colon\r\n\r\n", `malformed header "no colon"`},
+		{"Content-Length: -1\r\n\r\n", `invalid Content-Length " -1"`},
+		{"Content-Length: x\r\n\r\n", `invalid Content-Length " x"`},
+		{fmt.Sprintf("Content-Length: %d\r\n\r\n", MaxContentLength+1),
+			fmt.Sprintf("Content-Length %d exceeds the limit of %d", MaxContentLength+1, MaxContentLength)},
+		{"X-Long: " + strings.Repeat("x", 8192) + "\r\n\r\n", "header line longer than 4096 bytes"},
+		{"Content-Length: 10\r\n\r\n{}", "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		_, err := Read(bufio.NewReader(strings.NewReader(tt.input)))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Content-Length: 7\r\n\r\n{\"a\":1}"
+	if out.String() != expected {
+		t.Errorf("wrong message. expected=%q, got=%q", expected, out.String())
+	}
+
+	content, err := Read(bufio.NewReader(&out))
+	if err != nil || string(content) != `{"a":1}` {
+		t.Errorf("message did not read back. got=%q, %v", content, err)
+	}
+}